@@ -0,0 +1,123 @@
+package identity
+
+import "bytes"
+
+// Config holds optional settings which extend the built-in matchers.
+// A nil *Config uses the built-in matchers only; the package-level
+// Hash, HashAll, HashAllSpans, and Equal functions use a nil *Config.
+type Config struct {
+	// Opam treats opam-style "name.VERSION" names as versioned (see Opam).
+	Opam bool
+
+	// Matchers are custom matchers which are tried in order before the built-in matchers.
+	Matchers []Matcher
+
+	// MatchersLast tries Matchers after the built-in matchers instead of before, so they
+	// only apply to names that no built-in matcher recognizes.
+	MatchersLast bool
+
+	// ConcatSpans hashes two-span identities as the concatenation of both spans (with a
+	// separator) instead of XOR-ing the hashes of each span. XOR is order-independent and
+	// cancels out identical spans, so swapped or repeated spans always collide; hashing the
+	// concatenation avoids this at a small cost in speed.
+	ConcatSpans bool
+
+	// AssetExtensions replaces DefaultAssetExtensions as the extensions recognized by AssetPack
+	// when non-nil. An empty non-nil slice disables AssetPack.
+	AssetExtensions []string
+
+	// EmbeddedExtensions replaces DefaultEmbeddedExtensions as the extensions recognized by
+	// Embedded when non-nil. An empty non-nil slice disables Embedded.
+	EmbeddedExtensions []string
+
+	// OpaqueDigests treats names with a path segment starting with "sha256:" or "sha512:"
+	// (see HasDigest) as having no identity span, so they only ever match exactly. It takes
+	// precedence over Matchers.
+	OpaqueDigests bool
+}
+
+// Matcher detects the identity spans of a filename using the same convention as Spans:
+// identity = bs[:j] + bs[s:e], with s == e when there is no second span.
+// A j of 0 reports no match so that the next matcher is tried.
+// Results with spans outside of bs are also treated as no match.
+type Matcher func(bs []byte) (j, s, e int)
+
+// Spans returns the identity spans of a filename using the configured matchers
+// before (or with MatchersLast, after) the built-in matchers.
+func (c *Config) Spans(bs []byte) (j, s, e int) {
+	if c == nil {
+		return Spans(bs)
+	}
+
+	if c.OpaqueDigests && HasDigest(bs) {
+		return len(bs), 0, 0
+	}
+
+	if !c.MatchersLast {
+		if j, s, e, ok := c.custom(bs); ok {
+			return j, s, e
+		}
+	}
+
+	j, s, e = c.builtin(bs)
+
+	// The built-in matchers fall back to the whole name when nothing matches.
+	if c.MatchersLast && j == len(bs) && s == e {
+		if j, s, e, ok := c.custom(bs); ok {
+			return j, s, e
+		}
+	}
+
+	return j, s, e
+}
+
+// custom returns the spans of the first custom matcher which matches bs.
+func (c *Config) custom(bs []byte) (j, s, e int, ok bool) {
+	for _, m := range c.Matchers {
+		if j, s, e := m(bs); j > 0 && j <= len(bs) && 0 <= s && s <= e && e <= len(bs) {
+			return j, s, e, true
+		}
+	}
+
+	return 0, 0, 0, false
+}
+
+// builtin returns the spans of bs using the configured built-in matchers.
+func (c *Config) builtin(bs []byte) (j, s, e int) {
+	if c.Opam {
+		if r := Opam(bs); r > 0 {
+			return r, 0, 0
+		}
+	}
+
+	if c.AssetExtensions == nil && c.EmbeddedExtensions == nil {
+		return Spans(bs)
+	}
+
+	assetExts, embeddedExts := c.AssetExtensions, c.EmbeddedExtensions
+	if assetExts == nil {
+		assetExts = DefaultAssetExtensions
+	}
+	if embeddedExts == nil {
+		embeddedExts = DefaultEmbeddedExtensions
+	}
+
+	return spans(bs, assetExts, embeddedExts)
+}
+
+// HasDigest reports whether a path segment of bs starts with a "sha256:" or "sha512:" digest,
+// as in "blobs/sha256:4f3a9c1b...". Digests following "@" in an image reference
+// ("repo@sha256:...") are not path segments and are left to ImageRef.
+func HasDigest(bs []byte) bool {
+	for {
+		if bytes.HasPrefix(bs, []byte("sha256:")) || bytes.HasPrefix(bs, []byte("sha512:")) {
+			return true
+		}
+
+		n := bytes.IndexByte(bs, '/')
+		if n < 0 {
+			return false
+		}
+		bs = bs[n+1:]
+	}
+}
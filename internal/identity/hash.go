@@ -1,6 +1,7 @@
 package identity
 
 import (
+	"context"
 	"hash/maphash"
 	"sync"
 	"unsafe"
@@ -9,15 +10,84 @@ import (
 // High bit to distinguish exact matches from identity matches within a shared map.
 const ExactFlag uint64 = 1 << 63
 
+// CheckInterval is the number of files processed between cancellation checks.
+const CheckInterval = 4096
+
+// Span holds the identity span boundaries of a file name as returned by Spans.
+// The identity is name[:J] + name[S:E].
+type Span struct {
+	J, S, E int
+}
+
 // HashAll computes the identity and exact hashes for all strings in parallel.
 func HashAll(files []string, workers int, seed maphash.Seed) ([]uint64, []uint64) {
+	return (*Config)(nil).HashAll(files, workers, seed)
+}
+
+// HashAll computes the identity and exact hashes for all strings in parallel using the configured matchers.
+func (c *Config) HashAll(files []string, workers int, seed maphash.Seed) ([]uint64, []uint64) {
+	idMatch, exMatch, _ := c.HashAllContext(context.Background(), files, workers, seed)
+	return idMatch, exMatch
+}
+
+// HashAllContext is like HashAll but stops early and returns ctx.Err() when ctx is done.
+// Workers check ctx after every CheckInterval files.
+func (c *Config) HashAllContext(ctx context.Context, files []string, workers int, seed maphash.Seed) ([]uint64, []uint64, error) {
 	length := len(files)
 	if length == 0 {
-		return []uint64{}, []uint64{}
+		return []uint64{}, []uint64{}, nil
 	}
 
 	idMatch, exMatch := make([]uint64, length), make([]uint64, length)
+	done := ctx.Done()
 
+	parallel(length, workers, func(low, high int) {
+		for i := low; i < high; i++ {
+			if done != nil && (i-low)%CheckInterval == 0 && Cancelled(done) {
+				return
+			}
+			idMatch[i], exMatch[i] = c.Hash(files[i], seed)
+		}
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return idMatch, exMatch, nil
+}
+
+// HashAllSpans computes the identity and exact hashes for all strings in parallel
+// along with the identity spans used to compute them.
+// This allows callers to reuse the spans instead of calling Spans again.
+func HashAllSpans(files []string, workers int, seed maphash.Seed) ([]uint64, []uint64, []Span) {
+	return (*Config)(nil).HashAllSpans(files, workers, seed)
+}
+
+// HashAllSpans computes the identity and exact hashes and spans for all strings in parallel using the configured matchers.
+func (c *Config) HashAllSpans(files []string, workers int, seed maphash.Seed) ([]uint64, []uint64, []Span) {
+	length := len(files)
+	if length == 0 {
+		return []uint64{}, []uint64{}, []Span{}
+	}
+
+	idMatch, exMatch, spans := make([]uint64, length), make([]uint64, length), make([]Span, length)
+
+	parallel(length, workers, func(low, high int) {
+		for i := low; i < high; i++ {
+			bs := unsafe.Slice(unsafe.StringData(files[i]), len(files[i]))
+			j, s, e := c.Spans(bs)
+			spans[i] = Span{j, s, e}
+			idMatch[i], exMatch[i] = c.hashSpans(bs, seed, j, s, e)
+		}
+	})
+
+	return idMatch, exMatch, spans
+}
+
+// parallel splits [0, length) into contiguous chunks and calls fn for each chunk
+// on its own goroutine, waiting for all of them to finish.
+func parallel(length, workers int, fn func(low, high int)) {
 	chunk := max(1, (length+workers-1)/workers)
 
 	var wg sync.WaitGroup
@@ -31,43 +101,80 @@ func HashAll(files []string, workers int, seed maphash.Seed) ([]uint64, []uint64
 		high := min(low+chunk, length)
 
 		wg.Go(func() {
-			for i := low; i < high; i++ {
-				idMatch[i], exMatch[i] = Hash(files[i], seed)
-			}
+			fn(low, high)
 		})
 	}
 	wg.Wait()
+}
 
-	return idMatch, exMatch
+// Cancelled reports whether done is closed without blocking.
+func Cancelled(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
 }
 
 // Hash computes the identity hash and exact match hash for a file path.
 // Both hashes have the high bit cleared to leave room for the exactMatch flag.
 func Hash(s string, seed maphash.Seed) (uint64, uint64) {
+	return (*Config)(nil).Hash(s, seed)
+}
+
+// Hash computes the identity hash and exact match hash for a file path using the configured matchers.
+func (c *Config) Hash(s string, seed maphash.Seed) (uint64, uint64) {
 	bs := unsafe.Slice(unsafe.StringData(s), len(s))
-	length := len(bs)
+	j, sj, ej := c.Spans(bs)
 
-	exact := maphash.Bytes(seed, bs) &^ ExactFlag
+	return c.hashSpans(bs, seed, j, sj, ej)
+}
 
-	if length == 0 {
+// hashSpans computes the identity and exact hashes for bs given its identity spans.
+// Files without a detected identity use the exact hash for both.
+// Two-span identities combine the prefix and suffix hashes with XOR,
+// or hash their concatenation when Config.ConcatSpans is set.
+func (c *Config) hashSpans(bs []byte, seed maphash.Seed, j, s, e int) (uint64, uint64) {
+	switch {
+	case j == len(bs) && s == e:
+		exact := maphash.Bytes(seed, bs) &^ ExactFlag
 		return exact, exact
+	case s != e && c != nil && c.ConcatSpans:
+		var h maphash.Hash
+		h.SetSeed(seed)
+		h.Write(bs[:j])
+		h.WriteByte(0)
+		h.Write(bs[s:e])
+		return h.Sum64() &^ ExactFlag, maphash.Bytes(seed, bs) &^ ExactFlag
 	}
 
-	if i := Soname(bs); i > 0 {
-		return maphash.Bytes(seed, bs[:i]) &^ ExactFlag, exact
+	id, exact := hashPrefix(bs, seed, j)
+	if s != e {
+		id ^= maphash.Bytes(seed, bs[s:e])
 	}
 
-	if i, j := Script(bs); i > 0 {
-		return (maphash.Bytes(seed, bs[:i]) ^ maphash.Bytes(seed, bs[j:])) &^ ExactFlag, exact
-	}
+	return id &^ ExactFlag, exact &^ ExactFlag
+}
 
-	if i, j := Embedded(bs); i > 0 {
-		return (maphash.Bytes(seed, bs[:i]) ^ maphash.Bytes(seed, bs[j:])) &^ ExactFlag, exact
+// incrementalMin is the identity prefix length from which hashPrefix hashes the prefix
+// and the whole name in a single pass. maphash hashes input in 128-byte blocks, so a
+// single pass only hashes the blocks of the prefix once, but its buffering costs more
+// than hashing shorter prefixes twice.
+const incrementalMin = 256
+
+// hashPrefix returns the hashes of bs[:j] and bs, which are identical to hashing each
+// with maphash.Bytes.
+func hashPrefix(bs []byte, seed maphash.Seed, j int) (uint64, uint64) {
+	if j < incrementalMin {
+		return maphash.Bytes(seed, bs[:j]), maphash.Bytes(seed, bs)
 	}
 
-	if i := Suffix(bs); i > 0 {
-		return maphash.Bytes(seed, bs[:i]) &^ ExactFlag, exact
-	}
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.Write(bs[:j])
+	prefix := h.Sum64()
+	h.Write(bs[j:])
 
-	return exact, exact
+	return prefix, h.Sum64()
 }
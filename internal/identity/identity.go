@@ -10,12 +10,30 @@ import (
 //
 // Two strings have the same identity if their identity spans are equal.
 // The identity span is the portion of the filename excluding version numbers.
+//
+// The lengths of the strings cannot be used to reject a pair early since versions
+// of different lengths (e.g., "1.9" and "1.10") still share an identity. However,
+// every non-empty identity starts at the first byte of the name, so names whose
+// first bytes differ never share an identity and Spans can be skipped entirely.
 func Equal(old, cur string) bool {
+	return (*Config)(nil).Equal(old, cur)
+}
+
+// Equal checks if two strings have the same identity using the configured matchers.
+func (c *Config) Equal(old, cur string) bool {
+	if old == cur {
+		return true
+	}
+
+	if len(old) == 0 || len(cur) == 0 || old[0] != cur[0] {
+		return false
+	}
+
 	obs := unsafe.Slice(unsafe.StringData(old), len(old))
 	cbs := unsafe.Slice(unsafe.StringData(cur), len(cur))
 
-	oj, os, oe := Spans(obs)
-	cj, cs, ce := Spans(cbs)
+	oj, os, oe := c.Spans(obs)
+	cj, cs, ce := c.Spans(cbs)
 
 	// Return early if the identities are different (unequal or different lengths).
 	if oj != cj || oe-os != ce-cs {
@@ -25,27 +43,212 @@ func Equal(old, cur string) bool {
 	return bytes.Equal(obs[:oj], cbs[:cj]) && bytes.Equal(obs[os:oe], cbs[cs:ce])
 }
 
+// Identity returns the identity of a filename: its first identity span followed by its
+// second, or the whole name when no pattern matches. Names which are Equal have the same
+// Identity, so it can be used to group names outside of a diff.
+func Identity(name string) string {
+	return (*Config)(nil).Identity(name)
+}
+
+// Identity returns the identity of a filename using the configured matchers.
+func (c *Config) Identity(name string) string {
+	j, s, e := c.Spans(unsafe.Slice(unsafe.StringData(name), len(name)))
+	if s == e {
+		return name[:j]
+	}
+
+	return name[:j] + name[s:e]
+}
+
 // Spans returns the byte ranges that comprise the identity of a filename.
 // Returns (j, s, e) where [0:j] is the first span and [s:e] is the second span.
 // For most patterns, only the first span is used (s == e == 0).
 // For embedded versions and scripts, both spans are used (prefix [0:j] and suffix [s:len]).
+// Versions are recognized from ASCII digits only: fullwidth or other Unicode digits
+// (e.g., "app-１.２" or "app-١.٢") are part of the name, so such files match only exactly.
+// Every boundary falls next to an ASCII byte, so the spans of valid UTF-8 are valid UTF-8.
 func Spans(bs []byte) (j, s, e int) {
+	return spans(bs, DefaultAssetExtensions, DefaultEmbeddedExtensions)
+}
+
+// Flags for the bytes that spans checks for before running the matchers: separators,
+// directories, digests ("@"), TeX revisions (".r"), and timestamps ("T").
+const (
+	hasDash = 1 << iota
+	hasUnderscore
+	hasSlash
+	hasAt
+	hasR
+	hasT
+)
+
+// nameBytes maps each byte to its spans flag, if any.
+var nameBytes = [256]uint8{'-': hasDash, '_': hasUnderscore, '/': hasSlash, '@': hasAt, 'r': hasR, 'T': hasT}
+
+// spans implements Spans with configurable lists of asset pack and embedded version extensions.
+func spans(bs []byte, assetExts, embeddedExts []string) (j, s, e int) {
 	length := len(bs)
 
 	if r := Soname(bs); r > 0 {
 		return r, 0, 0
 	}
 
-	if r1, r2 := Script(bs); r1 > 0 {
+	// Most matchers need a specific extension or byte in the name, so look for those once
+	// and only run the matchers that can apply, in the usual order.
+	dot := bytes.LastIndexByte(bs, '.')
+	var ext []byte
+	if dot >= 0 {
+		ext = bs[dot+1:]
+	}
+
+	switch string(ext) {
+	case "post-deinstall", "post-install", "post-upgrade", "pre-install", "pre-upgrade", "trigger":
+		if r1, r2 := Script(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	var seen uint8
+	for _, c := range bs {
+		seen |= nameBytes[c]
+	}
+	dash, under, slash := seen&hasDash != 0, seen&hasUnderscore != 0, seen&hasSlash != 0
+	dots := dot > 0 && bytes.LastIndexByte(bs[:dot], '.') >= 0
+
+	if seen&hasAt != 0 {
+		if r := ImageRef(bs); r > 0 {
+			return r, 0, 0
+		}
+	}
+
+	if dash || under {
+		if r1, r2 := GoBinary(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	// Source package components end in ".dsc" or a compressed tarball or diff.
+	switch string(ext) {
+	case "dsc", "gz", "xz", "bz2":
+		if under {
+			if r1, r2 := DebSource(bs); r1 > 0 {
+				return r1, r2, length
+			}
+		}
+	}
+
+	// Package formats are identified by their extension, so at most one of them applies.
+	var r1, r2 int
+	switch string(ext) {
+	case "deb":
+		r1, r2 = Deb(bs)
+	case "rpm":
+		r1, r2 = Rpm(bs)
+	case "tgz":
+		r1, r2 = Npm(bs)
+	case "jar", "war", "ear":
+		r1, r2 = Jar(bs)
+	case "zip", "ziphash", "info", "mod", "lock":
+		r1, r2 = GoModule(bs)
+	case "gem":
+		r1, r2 = Gem(bs)
+	case "crate", "rlib", "rmeta":
+		r1, r2 = Crate(bs)
+	case "nupkg", "snupkg":
+		r1, r2 = Nupkg(bs)
+	case "conda", "bz2":
+		if r := Conda(bs); r > 0 {
+			return r, 0, 0
+		}
+	}
+	if r1 > 0 {
 		return r1, r2, length
 	}
 
-	if r1, r2 := Embedded(bs); r1 > 0 {
+	if dots && seen&hasR != 0 {
+		if r1, r2 := TexRevision(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	switch string(ext) {
+	case "tgz", "zip":
+		r1, r2 = Php(bs)
+	case "crx", "xpi":
+		r1, r2 = BrowserExtension(bs)
+	}
+	if r1 > 0 {
 		return r1, r2, length
 	}
 
-	if r1 := Suffix(bs); r1 > 0 {
-		return r1, 0, 0
+	if dot >= 0 && hasExt(bs[dot:], assetExts) {
+		if r1, r2 := AssetPack(bs, assetExts); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if dots && (isWebAsset(ext) || string(ext) == "map") {
+		if r1, r2 := ContentHash(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if dash && seen&hasT != 0 {
+		if r1, r2 := Timestamp(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if slash && (bytes.Contains(bs, []byte("/ebin/")) || bytes.Contains(bs, []byte("/priv/"))) {
+		if r1, r2 := Erlang(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	// Bundle paths are rare, so look for a framework directory once for XCFramework and Dylib.
+	framework := slash && bytes.Contains(bs, []byte("framework/"))
+
+	if framework || string(ext) == "xcframework" {
+		if r1, r2 := XCFramework(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	switch string(ext) {
+	case "ko", "xz", "gz", "zst":
+		if r1, r2 := Kmod(bs); r1 > 0 {
+			return r1, r2, r2 + 3
+		}
+	}
+
+	if len(ext) == len("dll") && hasSuffixFold(ext, "dll") {
+		if r1, r2 := Dll(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if dots && hasExt(bs[dot:], embeddedExts) {
+		if r1, r2 := Embedded(bs, embeddedExts); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if framework || string(ext) == "dylib" {
+		if r1, r2 := Dylib(bs); r1 > 0 {
+			return r1, r2, length
+		}
+	}
+
+	if dash {
+		if r1 := Suffix(bs); r1 > 0 {
+			return r1, 0, 0
+		}
+	}
+
+	if dash || dot >= 0 {
+		if r1, r2 := DateStamp(bs); r1 > 0 {
+			return r1, r2, length
+		}
 	}
 
 	return length, 0, 0
@@ -67,9 +270,292 @@ func Soname(bs []byte) int {
 	return 0
 }
 
+// Erlang detects versioned OTP application directories in Erlang/Elixir releases: lib/name-VERSION/subdir/...
+// Examples:
+// "lib/cowboy-2.10.0/ebin/cowboy.beam"
+// "myapp/lib/stdlib-5.2/ebin/lists.beam"
+//
+// The application directory must be directly under a "lib" directory and be followed by "ebin"
+// or "priv". The other standard subdirectories ("include", "src", "c_src") are not accepted,
+// since C toolchain trees such as "usr/lib/gcc-12/include" share the same layout.
+// Hex package tarballs ("pkg-1.2.3.tar") are already handled by Suffix.
+//
+// Returns (nameEnd, versionEnd) where identity = name[:nameEnd] + name[versionEnd:],
+// or (0, 0) if the pattern is not detected.
+func Erlang(bs []byte) (int, int) {
+	for off := 0; len(bs)-off >= 15; {
+		k := bytes.Index(bs[off:], []byte("lib/"))
+		if k < 0 {
+			break
+		}
+		k += off
+		off = k + 4
+
+		if k > 0 && bs[k-1] != '/' {
+			continue
+		}
+
+		// Find the end of the application directory and the separator before the version.
+		end := bytes.IndexByte(bs[off:], '/')
+		if end < 0 {
+			break
+		}
+		end += off
+
+		i := end - 1
+		for i > off && (bs[i]-'0' < 10 || bs[i] == '.') {
+			i--
+		}
+		if i == off || bs[i] != '-' || bs[i+1]-'0' >= 10 || bs[end-1] == '.' {
+			continue
+		}
+
+		sub := bs[end+1:]
+		if n := bytes.IndexByte(sub, '/'); n > 0 {
+			switch string(sub[:n]) {
+			case "ebin", "priv":
+				return i, end
+			}
+		}
+	}
+
+	return 0, 0
+}
+
+// XCFramework detects versioned Swift xcframework bundles and the files within them.
+// Examples:
+// "Alamofire-5.8.1.xcframework/Info.plist"
+// "Frameworks/5.8.1/Alamofire.xcframework/ios-arm64/Alamofire.framework/Alamofire"
+//
+// The version is either part of the bundle name ("name-VERSION.xcframework") or the name
+// of the directory containing the bundle, and the bundle name is kept as the identity.
+// SwiftPM archives ("Package-1.2.3.zip") are already handled by Suffix.
+//
+// Returns (nameEnd, versionEnd) where identity = name[:nameEnd] + name[versionEnd:],
+// or (0, 0) if the pattern is not detected.
+func XCFramework(bs []byte) (int, int) {
+	const ext = ".xcframework"
+
+	x := bytes.Index(bs, []byte(ext))
+	if x < 1 || x+len(ext) < len(bs) && bs[x+len(ext)] != '/' {
+		return 0, 0
+	}
+
+	b := bytes.LastIndexByte(bs[:x], '/') + 1
+
+	// The version is part of the bundle name.
+	if d := bytes.LastIndexByte(bs[b:x], '-'); d > 0 && isVersion(bs[b+d+1:x]) {
+		return b + d, x
+	}
+
+	// The version is the name of the directory containing the bundle.
+	if b < 2 {
+		return 0, 0
+	}
+	p := bytes.LastIndexByte(bs[:b-1], '/') + 1
+	if p > 0 && isVersion(bs[p:b-1]) {
+		return p, b
+	}
+
+	return 0, 0
+}
+
+// isVersion reports whether v is a dotted numeric version such as "5.8.1" or "v2".
+func isVersion(v []byte) bool {
+	if len(v) > 1 && v[0] == 'v' {
+		v = v[1:]
+	}
+	if len(v) == 0 || v[0]-'0' >= 10 || v[len(v)-1] == '.' {
+		return false
+	}
+
+	for _, c := range v {
+		if c-'0' >= 10 && c != '.' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Kmod detects versioned kernel modules: name-VERSION.ko[.xz|.zst|.gz]
+// Examples:
+// "nvidia-535.154.05.ko"
+// "zfs-2.2.2.ko.zst"
+//
+// Compression suffixes are excluded from the identity along with the version,
+// so modules reconcile when a kernel package changes how it compresses them.
+// Modules without a version (like "i915.ko.xz") are not matched.
+//
+// Returns (nameEnd, koStart) where identity = name[:nameEnd] + ".ko",
+// or (0, 0) if the pattern is not detected.
+func Kmod(bs []byte) (int, int) {
+	end := len(bs)
+	if end < 6 {
+		return 0, 0
+	}
+
+	switch {
+	case bytes.HasSuffix(bs, []byte(".xz")), bytes.HasSuffix(bs, []byte(".gz")):
+		end -= 3
+	case bytes.HasSuffix(bs, []byte(".zst")):
+		end -= 4
+	}
+	if end < 6 || bs[end-3] != '.' || bs[end-2] != 'k' || bs[end-1] != 'o' {
+		return 0, 0
+	}
+
+	ko := end - 3
+	for i := ko - 1; i >= 1; i-- {
+		c := bs[i]
+		if c-'0' < 10 || c == '.' {
+			continue
+		}
+		if (c == '-' || c == '_') && i < ko-1 && bs[i+1]-'0' < 10 && bs[i-1] != '/' {
+			return i, ko
+		}
+		break
+	}
+
+	return 0, 0
+}
+
+// dllArches are the architecture tags recognized between the version and extension of a DLL.
+var dllArches = []string{"-x64", "-x86", "-arm64"}
+
+// Dll detects Windows DLLs with trailing ABI version tokens: name-N[-N...][-ARCH].dll
+// Examples:
+// "api-ms-win-core-file-l1-2-0.dll"
+// "vcruntime140_1.dll"
+// "libcrypto-3-x64.dll"
+//
+// Each token is a "-" or "_" followed by digits, so "libssl-1_1-x64.dll" and
+// "libssl-3-x64.dll" share the identity "libssl" + "-x64.dll". An architecture tag
+// (-x64, -x86, or -arm64) stays part of the identity so builds for different architectures
+// don't reconcile with each other. Digits attached directly to the name are part of it:
+// "vcruntime140" and "msvcp140" name a toolset ABI rather than a release, so
+// "vcruntime140_1.dll" has the identity "vcruntime140" + ".dll" while "vcruntime140.dll"
+// (no token) and "vcruntime150_1.dll" do not share it. The extension is matched without
+// regard to case, as DLL names often are upper case. Dotted versions such as
+// "foo.1.2.3.dll" are left to Embedded.
+//
+// Returns (nameEnd, tagStart) where identity = name[:nameEnd] + name[tagStart:],
+// or (0, 0) if the pattern is not detected.
+func Dll(bs []byte) (int, int) {
+	if !hasSuffixFold(bs, ".dll") {
+		return 0, 0
+	}
+
+	end := len(bs) - len(".dll")
+	for _, arch := range dllArches {
+		if hasSuffixFold(bs[:end], arch) {
+			end -= len(arch)
+			break
+		}
+	}
+
+	// Strip "-N" and "_N" tokens from the end of the name.
+	i := end
+	for {
+		k := i
+		for k > 0 && bs[k-1]-'0' < 10 {
+			k--
+		}
+		if k == i || k < 2 || bs[k-1] != '-' && bs[k-1] != '_' {
+			break
+		}
+		i = k - 1
+	}
+
+	if i == end || bs[i-1] == '/' {
+		return 0, 0
+	}
+
+	return i, end
+}
+
+// hasSuffixFold reports whether bs ends with the lower case suffix, ignoring the case of letters.
+func hasSuffixFold(bs []byte, suffix string) bool {
+	if len(bs) < len(suffix) {
+		return false
+	}
+
+	bs = bs[len(bs)-len(suffix):]
+	for i := range len(suffix) {
+		if c := bs[i]; c != suffix[i] && (c|32 != suffix[i] || suffix[i]-'a' >= 26) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Dylib detects versioned macOS libraries and framework bundles.
+// Examples:
+// "libfoo.1.dylib"
+// "Foo.framework/Versions/A/Foo"
+//
+// Files under a framework's "Versions/X/" directory (other than "Versions/Current/",
+// which always names the current version) share an identity across versions, so
+// "Foo.framework/Versions/A/Foo" and "Foo.framework/Versions/B/Foo" reconcile. Only the
+// innermost framework of nested frameworks is considered. Otherwise a single version number
+// before ".dylib" is removed; dotted versions such as "libbar.4.5.6.dylib" are left to Embedded.
+//
+// Returns (versionStart, versionEnd) where identity = name[:versionStart] + name[versionEnd:],
+// or (0, 0) if the pattern is not detected.
+func Dylib(bs []byte) (int, int) {
+	const versions = ".framework/Versions/"
+
+	if k := bytes.LastIndex(bs, []byte(versions)); k > 0 && bs[k-1] != '/' {
+		v := k + len(versions)
+		if n := bytes.IndexByte(bs[v:], '/'); n > 0 && string(bs[v:v+n]) != "Current" {
+			return v, v + n
+		}
+	}
+
+	const ext = ".dylib"
+	if !bytes.HasSuffix(bs, []byte(ext)) {
+		return 0, 0
+	}
+
+	// Find the single version number before the extension.
+	end := len(bs) - len(ext)
+	i := end
+	for i > 0 && bs[i-1]-'0' < 10 {
+		i--
+	}
+	if i == end || i < 2 || bs[i-1] != '.' || bs[i-2] == '/' {
+		return 0, 0
+	}
+
+	// Reject dotted versions ("libbar.4.5.dylib") but keep digits in the name ("libfoo2.1.dylib").
+	dot := i - 1
+	k := dot
+	for k > 0 && bs[k-1]-'0' < 10 {
+		k--
+	}
+	if k < dot && k > 0 && bs[k-1] == '.' {
+		return 0, 0
+	}
+
+	return dot, end
+}
+
+// DefaultEmbeddedExtensions are the library extensions recognized by Embedded unless configured otherwise.
+var DefaultEmbeddedExtensions = []string{".so", ".dylib", ".dll", ".a"}
+
 // Embedded detects embedded version pattern: name.VERSION.ext
+// Examples:
+// "foo.1.2.3.so"
+// "libbar.4.5.6.dylib"
+//
+// Only the given (library) extensions are recognized, so documents such as "rfc.1.2.3.txt"
+// or "chapter.1.2.3.html" whose numbering is part of their name are left alone.
+//
 // Returns (start, end) of the version portion, or (0, 0) if not found.
-func Embedded(bs []byte) (int, int) {
+func Embedded(bs []byte, exts []string) (int, int) {
+	// The shortest match is a 1-byte name, ".1.2.3", and a 2-byte extension, so shorter
+	// names are rejected early. The index checks below don't rely on these bounds.
 	length := len(bs)
 	if length < 9 {
 		return 0, 0
@@ -84,7 +570,7 @@ func Embedded(bs []byte) (int, int) {
 		}
 	}
 
-	if ext < 6 || ext == length-1 {
+	if ext < 6 || ext == length-1 || !hasExt(bs[ext:], exts) {
 		return 0, 0
 	}
 
@@ -97,19 +583,38 @@ func Embedded(bs []byte) (int, int) {
 		i--
 	}
 
-	// Require at least 2 dots in version (e.g., "1.2.3").
-	if dots >= 2 && i >= 0 && bs[i+1] == '.' && bs[i+2]-'0' < 10 {
-		return i + 1, ext
+	// The scan starts at ext-1, so start <= ext and the version is bs[start:ext].
+	// Require a non-empty name, a leading dot followed by a digit, and at least 2 dots
+	// (e.g., ".1.2.3").
+	start := i + 1
+	if dots >= 2 && i >= 0 && bs[start] == '.' && start+1 < ext && bs[start+1]-'0' < 10 {
+		return start, ext
 	}
 
 	return 0, 0
 }
 
+// hasExt reports whether ext is one of exts.
+func hasExt(ext []byte, exts []string) bool {
+	for _, e := range exts {
+		if string(ext) == e {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Script detects script file patterns with checksums.
 // Examples:
 // "alpine-baselayout-3.6.8-r1.Q17OteNVXn9/iSXcJI1Vf8x0TVc9Y=.post-install"
 // "busybox-1.37.0-r12.Q1sSNCl4MTQ0d1V/0NTXAhIjY7Nqo=.trigger"
 //
+// The checksum is base64 (or base64url) and may contain '/', '+', '-', '_', and trailing
+// '=' padding. Since a '/' in the checksum is indistinguishable from a path separator, the
+// checksum is found by scanning back from the script suffix rather than from the last '/',
+// while the package version must follow the checksum's own path segment.
+//
 // Returns (pkgEnd, scriptStart) where identity = name[:pkgEnd] + name[scriptStart:].
 // or (0, 0) if the pattern not detected.
 func Script(bs []byte) (int, int) {
@@ -153,7 +658,13 @@ func Script(bs []byte) (int, int) {
 		return 0, 0
 	}
 
-	for i := checksumStart - 1; i >= 1; i-- {
+	for _, c := range bs[checksumStart+3 : start] {
+		if !isBase64(c) {
+			return 0, 0
+		}
+	}
+
+	for i := checksumStart - 1; i >= 1 && bs[i] != '/'; i-- {
 		if bs[i] == '-' && i+1 < checksumStart && bs[i+1]-'0' < 10 {
 			return i, start
 		}
@@ -162,37 +673,1209 @@ func Script(bs []byte) (int, int) {
 	return 0, 0
 }
 
-// Suffix detects version suffix pattern: name-VERSION or name-VERSION-rN.
-func Suffix(bs []byte) int {
-	length := len(bs)
-	i := length - 1
+// isBase64 reports whether c belongs to the standard or URL-safe base64 alphabet,
+// including '=' padding.
+func isBase64(c byte) bool {
+	return c-'0' < 10 || (c|32)-'a' < 26 || c == '+' || c == '/' || c == '-' || c == '_' || c == '='
+}
 
-	// Handle optional "-rN" revision suffixes (APK convention).
-	if i > 2 && bs[i]-'0' < 10 {
-		for i >= 0 && bs[i]-'0' < 10 {
-			i--
-		}
+// ImageRef detects digest-pinned container image references: repo[:tag]@sha256:DIGEST
+// Examples:
+// "cgr.dev/chainguard/static@sha256:0123abcd"
+// "registry:5000/app:1.2.3@sha512:0123abcd"
+//
+// Both the tag and the digest are excluded from the identity so that re-pinned
+// references reconcile by repository.
+//
+// Returns the position just after the repository, or 0 if not found.
+func ImageRef(bs []byte) int {
+	at := bytes.LastIndexByte(bs, '@')
+	if at < 1 {
+		return 0
+	}
 
-		if i > 0 && bs[i] == 'r' && bs[i-1] == '-' {
-			i -= 2
-		}
+	digest := bs[at+1:]
+	switch {
+	case bytes.HasPrefix(digest, []byte("sha256:")), bytes.HasPrefix(digest, []byte("sha512:")):
+		digest = digest[7:]
+	default:
+		return 0
 	}
 
-	// Scan backwards looking for "-N" pattern where N is a digit.
-	for i >= 0 {
-		c := bs[i]
-		if c == '-' && i+1 < length && bs[i+1]-'0' < 10 {
-			return i
+	if len(digest) == 0 {
+		return 0
+	}
+
+	for _, c := range digest {
+		if c-'0' >= 10 && c-'a' >= 6 {
+			return 0
 		}
+	}
 
-		// Continue scanning through valid version characters.
-		if c-'0' < 10 || c == '.' || c == '-' || c == '+' || (c|32)-'a' < 26 {
-			i--
-			continue
+	// Exclude an optional tag which follows the last path component.
+	end := at
+	if c := bytes.LastIndexByte(bs[:at], ':'); c > bytes.LastIndexByte(bs[:at], '/') {
+		end = c
+	}
+
+	if end < 1 || bs[end-1] == '/' {
+		return 0
+	}
+
+	return end
+}
+
+// GoBinary detects GoReleaser-style binary and archive names: name-vVERSION-os-arch[.ext]
+// Examples:
+// "myapp-v1.2.3-linux-amd64"
+// "myapp_1.2.3_darwin_arm64.tar.gz"
+//
+// Both `-` and `_` separators are supported and the `v` version prefix is optional.
+// The os and arch tokens are kept as part of the identity so binaries for different
+// platforms remain distinct.
+//
+// Returns (nameEnd, platformStart) where identity = name[:nameEnd] + name[platformStart:],
+// or (0, 0) if the pattern is not detected.
+func GoBinary(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 12 {
+		return 0, 0
+	}
+
+	// Skip any trailing extensions (e.g., ".exe" or ".tar.gz") after the arch token.
+	end := length
+	for i := length - 1; i >= 0 && !isSep(bs[i]) && bs[i] != '/'; i-- {
+		if bs[i] == '.' {
+			end = i
 		}
+	}
 
-		break
+	// "x86_64" contains the "_" separator so it is matched as a whole.
+	arch := lastToken(bs, end)
+	if end >= 8 && string(bs[end-6:end]) == "x86_64" && isSep(bs[end-7]) {
+		arch = end - 6
 	}
 
-	return 0
+	if arch <= 0 || !isGoArch(bs[arch:end]) {
+		return 0, 0
+	}
+
+	goos := lastToken(bs, arch-1)
+	if goos <= 0 || !isGoOS(bs[goos:arch-1]) {
+		return 0, 0
+	}
+
+	version := lastToken(bs, goos-1)
+	if version <= 1 {
+		return 0, 0
+	}
+
+	// Require an optional "v" followed by a digit and only digits or dots afterwards.
+	v := version
+	if bs[v] == 'v' {
+		v++
+	}
+
+	if v >= goos-1 || bs[v]-'0' >= 10 {
+		return 0, 0
+	}
+
+	for i := v; i < goos-1; i++ {
+		if bs[i]-'0' >= 10 && bs[i] != '.' {
+			return 0, 0
+		}
+	}
+
+	// The name must not be empty or end at a directory boundary.
+	if bs[version-2] == '/' {
+		return 0, 0
+	}
+
+	return version - 1, goos - 1
+}
+
+// DebSource detects Debian source package components: name_VERSION.component
+// Examples:
+// "foo_1.2.3-1.dsc"
+// "foo_1.2.3.orig.tar.gz"
+// "foo_1.2.3-1.debian.tar.xz"
+//
+// The component (".dsc", ".orig.tar.*", ".debian.tar.*", or ".diff.gz") is kept as
+// part of the identity so each component of a source package reconciles separately.
+//
+// Returns (nameEnd, componentStart) where identity = name[:nameEnd] + name[componentStart:],
+// or (0, 0) if the pattern is not detected.
+func DebSource(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 7 || bytes.IndexByte(bs, '_') < 0 {
+		return 0, 0
+	}
+
+	var start int
+	switch {
+	case bytes.HasSuffix(bs, []byte(".dsc")):
+		start = length - 4
+	case bytes.HasSuffix(bs, []byte(".diff.gz")):
+		start = length - 8
+	default:
+		start = max(bytes.LastIndex(bs, []byte(".orig.tar.")), bytes.LastIndex(bs, []byte(".debian.tar.")))
+	}
+
+	if start < 3 {
+		return 0, 0
+	}
+
+	// Scan backwards through the version looking for the "_" separator.
+	for i := start - 1; i >= 1; i-- {
+		c := bs[i]
+		if c == '_' {
+			if bs[i+1]-'0' < 10 && bs[i-1] != '/' {
+				return i, start
+			}
+			break
+		}
+
+		if c-'0' < 10 || c == '.' || c == '-' || c == '+' || c == '~' || (c|32)-'a' < 26 {
+			continue
+		}
+
+		break
+	}
+
+	return 0, 0
+}
+
+// Deb detects Debian binary package file names: name_[EPOCH:]VERSION_ARCH.deb
+// Examples:
+// "nginx_1.24.0-1_amd64.deb"
+// "libssl3_3.0.11-1~deb12u2_amd64.deb"
+// "libc6_1:2.36-9_arm64.deb" (the epoch may also be escaped as "1%3a2.36-9")
+//
+// The version (including any epoch) and architecture are excluded from the identity
+// so that packages reconcile across versions and architectures.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Deb(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 9 || !bytes.HasSuffix(bs, []byte(".deb")) {
+		return 0, 0
+	}
+
+	ext := length - 4
+	arch := bytes.LastIndexByte(bs[:ext], '_')
+	if arch < 3 || arch == ext-1 {
+		return 0, 0
+	}
+	for _, c := range bs[arch+1 : ext] {
+		if c-'0' >= 10 && c-'a' >= 26 {
+			return 0, 0
+		}
+	}
+
+	ver := bytes.LastIndexByte(bs[:arch], '_')
+	if ver < 1 || ver == arch-1 || bs[ver-1] == '/' || bytes.IndexByte(bs[ver:arch], '/') >= 0 {
+		return 0, 0
+	}
+
+	// Skip the epoch, if any, before requiring the version to start with a digit.
+	v := ver + 1
+	for v < arch && bs[v]-'0' < 10 {
+		v++
+	}
+	switch {
+	case v < arch && bs[v] == ':':
+		v++
+	case v+2 < arch && bs[v] == '%' && bs[v+1] == '3' && bs[v+2]|32 == 'a':
+		v += 3
+	default:
+		v = ver + 1
+	}
+	if v == arch || bs[v]-'0' >= 10 {
+		return 0, 0
+	}
+
+	return ver, ext
+}
+
+// Rpm detects RPM package file names: name-VERSION-RELEASE.ARCH.rpm
+// Examples:
+// "bash-5.2.15-1.fc39.x86_64.rpm"
+// "libfoo2-1.0-1.x86_64.rpm"
+//
+// The version, release, and architecture are excluded from the identity so that packages
+// reconcile across versions and architectures. Source packages (".src.rpm" and ".nosrc.rpm")
+// keep their architecture so they remain distinct from binary packages.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Rpm(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 12 || !bytes.HasSuffix(bs, []byte(".rpm")) {
+		return 0, 0
+	}
+
+	ext := length - 4
+	arch := bytes.LastIndexByte(bs[:ext], '.')
+	if arch < 5 || arch == ext-1 {
+		return 0, 0
+	}
+	switch string(bs[arch+1 : ext]) {
+	case "src", "nosrc":
+		ext = arch
+	}
+
+	// The release follows the last dash and the version the dash before it.
+	rel := bytes.LastIndexByte(bs[:arch], '-')
+	if rel < 3 || rel == arch-1 {
+		return 0, 0
+	}
+	ver := bytes.LastIndexByte(bs[:rel], '-')
+	if ver < 1 || ver == rel-1 || bs[ver+1]-'0' >= 10 || bytes.IndexByte(bs[ver:arch], '/') >= 0 {
+		return 0, 0
+	}
+
+	return ver, ext
+}
+
+// Npm detects npm package tarballs: name-MAJOR.MINOR[...].tgz
+// Examples:
+// "lodash-4.17.21.tgz"
+// "@babel/core-7.24.0.tgz"
+// "babel-plugin-foo-1.2.3-beta.1.tgz"
+//
+// The version starts at the last dash followed by a "MAJOR.MINOR" prefix, so dashes
+// inside package names and prerelease tags don't end the name early.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Npm(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 9 || !bytes.HasSuffix(bs, []byte(".tgz")) {
+		return 0, 0
+	}
+
+	ext := length - 4
+	for i := ext - 4; i >= 1; i-- {
+		c := bs[i]
+		if c == '/' {
+			break
+		}
+		if c != '-' || bs[i-1] == '/' || bs[i-1] == '@' {
+			continue
+		}
+
+		// Require a "MAJOR.MINOR" prefix after the dash.
+		j := i + 1
+		for j < ext && bs[j]-'0' < 10 {
+			j++
+		}
+		if j > i+1 && j+1 < ext && bs[j] == '.' && bs[j+1]-'0' < 10 {
+			return i, ext
+		}
+	}
+
+	return 0, 0
+}
+
+// Jar detects Maven artifacts: artifactId-VERSION[-classifier].{jar,war,ear}
+// Examples:
+// "guava-33.0.0-jre.jar"
+// "commons-lang3-3.14.0.jar"
+// "foo-1.2.3-SNAPSHOT.jar"
+// "foo-1.2.3-sources.jar"
+//
+// The version starts at the first dash in the file name that is followed by a digit,
+// so digits that are part of the artifact id (like "commons-lang3") are kept.
+// The "-sources", "-javadoc", and "-tests" classifiers name separate artifacts and are kept
+// as part of the identity; any other classifier is treated as part of the version.
+//
+// Returns (nameEnd, classifierStart) where identity = name[:nameEnd] + name[classifierStart:],
+// or (0, 0) if the pattern is not detected.
+func Jar(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 7 || bs[length-4] != '.' {
+		return 0, 0
+	}
+
+	switch string(bs[length-3:]) {
+	case "jar", "war", "ear":
+	default:
+		return 0, 0
+	}
+
+	end := length - 4
+	for _, c := range [...]string{"-sources", "-javadoc", "-tests"} {
+		if bytes.HasSuffix(bs[:end], []byte(c)) {
+			end -= len(c)
+			break
+		}
+	}
+
+	start := bytes.LastIndexByte(bs[:end], '/') + 1
+	for i := start + 1; i < end-1; i++ {
+		if bs[i] == '-' && bs[i+1]-'0' < 10 {
+			return i, end
+		}
+	}
+
+	return 0, 0
+}
+
+// Gem detects Ruby gems: name-VERSION[-platform].gem
+// Examples:
+// "rails-7.1.3.gem"
+// "nokogiri-1.16.0-x86_64-linux.gem"
+// "rack-3.1.0.beta1.gem"
+//
+// The version starts at the first dash in the file name that is followed by a digit and
+// runs to the next dash, so digits in the gem name (like "aws-sdk-s3") are kept. RubyGems
+// versions never contain dashes (pre-releases are written "1.0.0.beta1" or "1.0.0.pre.rc1").
+// Anything after the version is the platform, which may contain dashes itself (like
+// "x86_64-linux-musl" or "universal-darwin-22") and is kept as part of the identity so that
+// gems built for different platforms don't reconcile with each other.
+//
+// Returns (nameEnd, platformStart) where identity = name[:nameEnd] + name[platformStart:],
+// or (0, 0) if the pattern is not detected.
+func Gem(bs []byte) (int, int) {
+	const ext = ".gem"
+
+	length := len(bs)
+	if length < 7 || !bytes.HasSuffix(bs, []byte(ext)) {
+		return 0, 0
+	}
+
+	end := length - len(ext)
+	start := bytes.LastIndexByte(bs[:end], '/') + 1
+	for i := start + 1; i < end-1; i++ {
+		if bs[i] != '-' || bs[i+1]-'0' >= 10 {
+			continue
+		}
+
+		// The version is made of letters, digits, and dots and must not end with a dot.
+		v := i + 1
+		for v < end && (bs[v]-'0' < 10 || bs[v] == '.' || (bs[v]|32)-'a' < 26) {
+			v++
+		}
+		if bs[v-1] == '.' {
+			return 0, 0
+		}
+
+		switch {
+		case v == end:
+			return i, end
+		case bs[v] == '-' && v+1 < end && (bs[v+1]|32)-'a' < 26:
+			return i, v
+		}
+
+		return 0, 0
+	}
+
+	return 0, 0
+}
+
+// Crate detects Rust crate archives and compiled crates.
+// Examples:
+// "serde-1.0.197.crate"
+// "libserde-abcdef0123456789.rlib"
+//
+// For ".crate" archives the version starts at the first dash followed by a number and a dot,
+// so digits in the crate name (like "h2-0.4.2" or "base64-0.21.7") are kept, and SemVer
+// pre-release and build metadata are part of the version. For compiled ".rlib" and ".rmeta"
+// files the identity drops the trailing "-HASH" of 8 to 16 lowercase hex digits that Cargo
+// derives from the crate's version and build settings.
+//
+// Returns (nameEnd, versionEnd) where identity = name[:nameEnd] + name[versionEnd:],
+// or (0, 0) if the pattern is not detected.
+func Crate(bs []byte) (int, int) {
+	var end int
+	switch {
+	case bytes.HasSuffix(bs, []byte(".crate")):
+		end = len(bs) - len(".crate")
+	case bytes.HasSuffix(bs, []byte(".rlib")):
+		return crateHash(bs, len(bs)-len(".rlib"))
+	case bytes.HasSuffix(bs, []byte(".rmeta")):
+		return crateHash(bs, len(bs)-len(".rmeta"))
+	default:
+		return 0, 0
+	}
+
+	start := bytes.LastIndexByte(bs[:end], '/') + 1
+	for i := start + 1; i < end-2; i++ {
+		if bs[i] != '-' || bs[i+1]-'0' >= 10 {
+			continue
+		}
+
+		k := i + 1
+		for k < end && bs[k]-'0' < 10 {
+			k++
+		}
+		if k < end-1 && bs[k] == '.' && bs[k+1]-'0' < 10 {
+			return i, end
+		}
+	}
+
+	return 0, 0
+}
+
+// crateHash returns the spans of a compiled crate whose "-HASH" ends at end (see Crate).
+func crateHash(bs []byte, end int) (int, int) {
+	i := end
+	for i > 0 && end-i < 16 && (bs[i-1]-'0' < 10 || bs[i-1]-'a' < 6) {
+		i--
+	}
+	if end-i < 8 || i < 2 || bs[i-1] != '-' || bs[i-2] == '/' {
+		return 0, 0
+	}
+
+	return i - 1, end
+}
+
+// Nupkg detects NuGet packages: id.VERSION.nupkg (or .snupkg for symbol packages)
+// Examples:
+// "newtonsoft.json.13.0.3.nupkg"
+// "microsoft.netcore.app.ref.8.0.0-preview.7.23375.6.nupkg"
+//
+// Both the package id and the version are dot-separated, so the version starts at the first
+// ".N" after which the rest of the name is a valid NuGet version: 2 to 4 numeric parts,
+// optionally followed by a "-" and a pre-release label. Numeric id segments followed by
+// other text (like "foo.2.bar.1.0.0") stay part of the id. Only the file name is considered;
+// the "id/VERSION/" directories of a restore cache still differ between versions.
+//
+// Returns (idEnd, extStart) where identity = name[:idEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Nupkg(bs []byte) (int, int) {
+	var end int
+	switch {
+	case bytes.HasSuffix(bs, []byte(".nupkg")):
+		end = len(bs) - len(".nupkg")
+	case bytes.HasSuffix(bs, []byte(".snupkg")):
+		end = len(bs) - len(".snupkg")
+	default:
+		return 0, 0
+	}
+
+	start := bytes.LastIndexByte(bs[:end], '/') + 1
+	for i := start + 1; i < end-3; i++ {
+		if bs[i] == '.' && bs[i+1]-'0' < 10 && isNuGetVersion(bs[i+1:end]) {
+			return i, end
+		}
+	}
+
+	return 0, 0
+}
+
+// isNuGetVersion reports whether v is a NuGet version such as "13.0.3" or "8.0.0-preview.7".
+func isNuGetVersion(v []byte) bool {
+	parts := 1
+	i := 0
+	for ; i < len(v); i++ {
+		c := v[i]
+		if c == '-' {
+			break
+		}
+		if c == '.' {
+			// Parts must be non-empty.
+			if i == 0 || v[i-1] == '.' {
+				return false
+			}
+			parts++
+			continue
+		}
+		if c-'0' >= 10 {
+			return false
+		}
+	}
+	if parts < 2 || parts > 4 || v[i-1] == '.' {
+		return false
+	}
+
+	// The pre-release label must be non-empty.
+	if i < len(v) {
+		label := v[i+1:]
+		if len(label) == 0 {
+			return false
+		}
+		for _, c := range label {
+			if c-'0' >= 10 && (c|32)-'a' >= 26 && c != '.' && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// GoModule detects Go module cache downloads: module/@v/vMAJOR.MINOR.PATCH.ext
+// Examples:
+// "github.com/foo/bar/@v/v1.2.3.zip"
+// "github.com/foo/bar/v2/@v/v2.0.1.mod"
+// "github.com/foo/bar/@v/v0.0.0-20230101000000-abcdef123456.info"
+// "github.com/foo/baz/@v/v3.1.0+incompatible.lock"
+//
+// The major version is kept as part of the identity so that each major version of a
+// module (including "+incompatible" ones that share a module path) reconciles separately.
+// The file extension (".zip", ".ziphash", ".info", ".mod", or ".lock") is kept as well.
+//
+// Returns (majorEnd, extStart) where identity = name[:majorEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func GoModule(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 14 {
+		return 0, 0
+	}
+
+	ext := bytes.LastIndexByte(bs, '.')
+	if ext < 10 {
+		return 0, 0
+	}
+	switch string(bs[ext+1:]) {
+	case "zip", "ziphash", "info", "mod", "lock":
+	default:
+		return 0, 0
+	}
+
+	v := bytes.LastIndex(bs[:ext], []byte("/@v/v"))
+	if v < 1 {
+		return 0, 0
+	}
+
+	major := v + 5
+	for major < ext && bs[major]-'0' < 10 {
+		major++
+	}
+	if major == v+5 || major == ext || bs[major] != '.' || bytes.IndexByte(bs[major:ext], '/') >= 0 {
+		return 0, 0
+	}
+
+	return major, ext
+}
+
+// Conda detects Conda package names: name-VERSION-BUILD.conda or name-VERSION-BUILD.tar.bz2
+// Examples:
+// "numpy-1.26.4-py312h1234567_0.conda"
+// "ca-certificates-2024.2.2-h06a4308_0.tar.bz2"
+//
+// Returns the position of the separator before the version, or 0 if not found.
+func Conda(bs []byte) int {
+	length := len(bs)
+	if length < 12 {
+		return 0
+	}
+
+	var end int
+	switch {
+	case bytes.HasSuffix(bs, []byte(".conda")):
+		end = length - 6
+	case bytes.HasSuffix(bs, []byte(".tar.bz2")):
+		end = length - 8
+	default:
+		return 0
+	}
+
+	// Scan backwards through the build string.
+	i := end - 1
+	for i >= 0 && bs[i] != '-' {
+		c := bs[i]
+		if c-'0' >= 10 && (c|32)-'a' >= 26 && c != '_' && c != '.' && c != '+' {
+			return 0
+		}
+		i--
+	}
+
+	if i < 0 || i == end-1 {
+		return 0
+	}
+
+	// Scan backwards through the version which must start with a digit.
+	j := i - 1
+	for j >= 0 && bs[j] != '-' {
+		c := bs[j]
+		if c-'0' >= 10 && (c|32)-'a' >= 26 && c != '_' && c != '.' && c != '+' && c != '!' {
+			return 0
+		}
+		j--
+	}
+
+	if j < 1 || j == i-1 || bs[j+1]-'0' >= 10 || bs[j-1] == '/' {
+		return 0
+	}
+
+	return j
+}
+
+// TexRevision detects TeX Live revision suffixes: name.rREVISION.ext
+// Examples:
+// "foo.r12345.tar.xz"
+// "foo.doc.r12345.tar.xz"
+//
+// Unlike the APK "-rN" suffix, TeX Live revisions are separated by a dot and
+// are required to have at least three digits to avoid matching short tokens.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func TexRevision(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 8 {
+		return 0, 0
+	}
+
+	for i := length - 1; i >= 3; i-- {
+		if bs[i] == '/' {
+			break
+		}
+
+		// Look for ".rNNN." with at least three digits.
+		if bs[i] != '.' || bs[i-1]-'0' >= 10 {
+			continue
+		}
+
+		j := i - 1
+		for j >= 0 && bs[j]-'0' < 10 {
+			j--
+		}
+
+		if i-j-1 >= 3 && j >= 2 && bs[j] == 'r' && bs[j-1] == '.' && bs[j-2] != '/' {
+			return j - 1, i
+		}
+	}
+
+	return 0, 0
+}
+
+// Php detects PEAR package archives and versioned Composer vendor/name paths.
+// Examples:
+// "Package-1.2.3.tgz" (PEAR)
+// "Console_Getopt-1.4.3RC1.tgz" (PEAR)
+// "monolog/monolog/3.5.0.zip" (Composer)
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Php(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 9 {
+		return 0, 0
+	}
+
+	var ext int
+	switch {
+	case bytes.HasSuffix(bs, []byte(".tgz")):
+		ext = length - 4
+	case bytes.HasSuffix(bs, []byte(".zip")):
+		ext = length - 4
+	default:
+		return 0, 0
+	}
+
+	// Scan backwards through the version (digits, dots, and stability labels like "RC1").
+	i := ext - 1
+	for i >= 0 && (bs[i]-'0' < 10 || bs[i] == '.' || (bs[i]|32)-'a' < 26) {
+		i--
+	}
+
+	// The version must start with a digit (optionally prefixed with "v" for Composer).
+	v := i + 1
+	if i >= 0 && v < ext && bs[v] == 'v' && bs[i] == '/' {
+		v++
+	}
+	if i < 1 || v >= ext || bs[v]-'0' >= 10 {
+		return 0, 0
+	}
+
+	switch bs[i] {
+	case '-':
+		// PEAR archives only use the ".tgz" extension.
+		if ext == length-4 && bs[ext+1] == 't' && bs[i-1] != '/' {
+			return i, ext
+		}
+	case '/':
+		// Composer paths require both a vendor and a package directory
+		// and a dotted version to avoid matching arbitrary numbered files.
+		if bytes.IndexByte(bs[v:ext], '.') < 0 {
+			break
+		}
+		if j := bytes.LastIndexByte(bs[:i], '/'); j > 0 && j < i-1 && bs[j-1] != '/' {
+			return i, ext
+		}
+	}
+
+	return 0, 0
+}
+
+// BrowserExtension detects versioned browser extension packages: name-VERSION[-TOKEN].crx or .xpi
+// Examples:
+// "ext-1.2.3.crx"
+// "ublock_origin-1.55.0-an+fx.xpi"
+// "ext-1.2.3-aapocclcgogkmnckokdopfmhonfmgoek.crx"
+//
+// An optional trailing hash or extension ID token is excluded from the identity
+// along with the version so that re-signed or re-published packages still reconcile.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func BrowserExtension(bs []byte) (int, int) {
+	length := len(bs)
+	if length < 7 || bs[length-4] != '.' {
+		return 0, 0
+	}
+
+	ext := length - 4
+	switch string(bs[ext+1:]) {
+	case "crx", "xpi":
+	default:
+		return 0, 0
+	}
+
+	if i := extensionVersion(bs, ext); i > 0 {
+		return i, ext
+	}
+
+	// Skip an optional alphanumeric hash or ID token (e.g. "-an+fx" or a 32 character ID).
+	t := ext - 1
+	for t >= 0 && (bs[t]-'0' < 10 || (bs[t]|32)-'a' < 26 || bs[t] == '+') {
+		t--
+	}
+	if t < 1 || t == ext-1 || bs[t] != '-' && bs[t] != '_' {
+		return 0, 0
+	}
+
+	if i := extensionVersion(bs, t); i > 0 {
+		return i, ext
+	}
+
+	return 0, 0
+}
+
+// extensionVersion scans backwards from end over a dotted version starting with a digit
+// (optionally prefixed with "v") and returns the position of the separator before it, or 0.
+func extensionVersion(bs []byte, end int) int {
+	i := end - 1
+	for i >= 0 && (bs[i]-'0' < 10 || bs[i] == '.') {
+		i--
+	}
+
+	v := i + 1
+	if i >= 0 && bs[i] == 'v' {
+		i--
+	}
+	if i < 1 || v >= end || bs[v]-'0' >= 10 || bs[end-1] == '.' || bs[i] != '-' && bs[i] != '_' {
+		return 0
+	}
+
+	return i
+}
+
+// DefaultAssetExtensions are the extensions recognized by AssetPack unless configured otherwise.
+var DefaultAssetExtensions = []string{".zip", ".pak", ".bank"}
+
+// AssetPack detects versioned game and mod asset packs: name{_,-,.}[v]VERSION.ext
+// Examples:
+// "pack_v1.2.3.zip"
+// "textures-2.0.pak"
+// "sounds.v3.bank"
+//
+// Only the given extensions are recognized, and a "." separator requires the "v"
+// version prefix to avoid matching ordinary dotted names.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func AssetPack(bs []byte, exts []string) (int, int) {
+	dot := bytes.LastIndexByte(bs, '.')
+	if dot < 3 {
+		return 0, 0
+	}
+
+	found := false
+	for _, ext := range exts {
+		if string(bs[dot:]) == ext {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, 0
+	}
+
+	// Scan backwards through the version.
+	i := dot - 1
+	for i >= 0 && (bs[i]-'0' < 10 || bs[i] == '.') {
+		i--
+	}
+	if i == dot-1 || bs[i+1]-'0' >= 10 || bs[dot-1] == '.' || i < 1 {
+		return 0, 0
+	}
+
+	v := bs[i] == 'v'
+	if v {
+		i--
+	}
+	if i < 1 {
+		return 0, 0
+	}
+
+	switch bs[i] {
+	case '_', '-':
+		return i, dot
+	case '.':
+		if v {
+			return i, dot
+		}
+	}
+
+	return 0, 0
+}
+
+// ContentHash detects bundler output with a content hash: name.HASH[.chunk].ext
+// Examples:
+// "main.4f3a9c1b.js"
+// "vendor.ab12cd34.css"
+// "app.9f8e7d6c.chunk.js"
+// "main.4f3a9c1b.js.map"
+//
+// The hash must be 8 to 20 lowercase hexadecimal characters and the extension a known
+// web asset extension, so names like "jquery.min.js" and dotted versions are not matched.
+//
+// Returns (hashStart, hashEnd) where identity = name[:hashStart] + name[hashEnd:],
+// or (0, 0) if the pattern is not detected.
+func ContentHash(bs []byte) (int, int) {
+	end := len(bs)
+	if end < 12 {
+		return 0, 0
+	}
+
+	if bytes.HasSuffix(bs, []byte(".map")) {
+		end -= 4
+	}
+
+	ext := bytes.LastIndexByte(bs[:end], '.')
+	if ext < 10 {
+		return 0, 0
+	}
+	if !isWebAsset(bs[ext+1 : end]) {
+		return 0, 0
+	}
+
+	if bytes.HasSuffix(bs[:ext], []byte(".chunk")) || bytes.HasSuffix(bs[:ext], []byte(".bundle")) {
+		ext = bytes.LastIndexByte(bs[:ext], '.')
+	}
+
+	for i := ext - 1; i >= 1 && ext-i <= 21; i-- {
+		c := bs[i]
+		if c == '.' {
+			if ext-i > 8 && bs[i-1] != '/' {
+				return i, ext
+			}
+			break
+		}
+		if c-'0' >= 10 && c-'a' >= 6 {
+			break
+		}
+	}
+
+	return 0, 0
+}
+
+// isWebAsset reports whether ext (without the dot) is a script, stylesheet, image, or font extension.
+func isWebAsset(ext []byte) bool {
+	switch string(ext) {
+	case "js", "mjs", "cjs", "css", "html", "wasm", "svg", "png", "jpg", "jpeg", "gif", "webp", "avif", "ico", "woff", "woff2", "ttf", "eot":
+		return true
+	}
+
+	return false
+}
+
+// Timestamp detects generated file names with a timestamp suffix: name-YYYY-MM-DDThh-mm-ss[.ext]
+// Example: "report-2024-01-01T12-00-00.html"
+//
+// The timestamp must be a plausible date and time to avoid matching ordinary dashed names.
+//
+// Returns (nameEnd, extStart) where identity = name[:nameEnd] + name[extStart:],
+// or (0, 0) if the pattern is not detected.
+func Timestamp(bs []byte) (int, int) {
+	const size = len("-YYYY-MM-DDThh-mm-ss")
+
+	length := len(bs)
+	if length <= size {
+		return 0, 0
+	}
+
+	// The timestamp may be followed by the end of the name or any extension.
+	for e := length; e > size; e-- {
+		if e < length && bs[e] != '.' {
+			if bs[e] == '/' {
+				break
+			}
+			continue
+		}
+
+		if i := e - size; bs[i-1] != '/' && isTimestamp(bs[i:e]) {
+			return i, e
+		}
+	}
+
+	return 0, 0
+}
+
+// isTimestamp reports whether ts is a "-YYYY-MM-DDThh-mm-ss" timestamp.
+func isTimestamp(ts []byte) bool {
+	for i, c := range ts {
+		switch i {
+		case 0, 5, 8, 14, 17:
+			if c != '-' {
+				return false
+			}
+		case 11:
+			if c != 'T' {
+				return false
+			}
+		default:
+			if c-'0' >= 10 {
+				return false
+			}
+		}
+	}
+
+	num := func(i int) int { return int(ts[i]-'0')*10 + int(ts[i+1]-'0') }
+	month, day, hour, minute, second := num(6), num(9), num(12), num(15), num(18)
+
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31 && hour <= 23 && minute <= 59 && second <= 59
+}
+
+// Opam detects opam-style package names: name.VERSION
+// Examples:
+// "ocaml-base-compiler.5.1.0"
+// "dune.3.14.0"
+// "lwt.5.7.0~beta"
+//
+// Since "name.VERSION" is ambiguous with ordinary file names this matcher is only
+// used when enabled via Config.Opam.
+//
+// Returns the position of the dot before the version, or 0 if not found.
+func Opam(bs []byte) int {
+	length := len(bs)
+	base := bytes.LastIndexByte(bs, '/') + 1
+
+	for k := base + 1; k < length-1; k++ {
+		if bs[k] != '.' {
+			continue
+		}
+
+		i := k + 1
+		if bs[i] == 'v' && i+1 < length {
+			i++
+		}
+		if bs[i]-'0' >= 10 {
+			continue
+		}
+
+		// The version is made up of digits and dots with an optional
+		// "~", "+", or "-" suffix (e.g., "~beta" or "+dev").
+		for i < length && (bs[i]-'0' < 10 || bs[i] == '.') {
+			i++
+		}
+
+		if i < length && (bs[i] == '~' || bs[i] == '+' || bs[i] == '-') {
+			i++
+			for i < length && (bs[i]-'0' < 10 || bs[i] == '.' || (bs[i]|32)-'a' < 26) {
+				i++
+			}
+		}
+
+		if i == length && bs[length-1] != '.' {
+			return k
+		}
+	}
+
+	return 0
+}
+
+// isGoOS reports whether token is a GOOS value recognized by GoBinary.
+func isGoOS(token []byte) bool {
+	switch string(token) {
+	case "aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+		"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows":
+		return true
+	}
+	return false
+}
+
+// isGoArch reports whether token is a GOARCH value (or common GoReleaser alias) recognized by GoBinary.
+func isGoArch(token []byte) bool {
+	switch string(token) {
+	case "386", "all", "amd64", "arm", "arm64", "armv6", "armv7", "i386", "loong64", "mips",
+		"mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm", "x86_64":
+		return true
+	}
+	return false
+}
+
+// isSep reports whether c separates tokens in a versioned name.
+func isSep(c byte) bool {
+	return c == '-' || c == '_'
+}
+
+// lastToken returns the start of the separator-delimited token ending at end,
+// or -1 if the token is empty or not preceded by a separator.
+func lastToken(bs []byte, end int) int {
+	i := end - 1
+	for i >= 0 && !isSep(bs[i]) && bs[i] != '/' {
+		i--
+	}
+
+	if i < 0 || i == end-1 || !isSep(bs[i]) {
+		return -1
+	}
+
+	return i + 1
+}
+
+// Suffix detects version suffix pattern: name-VERSION or name-VERSION-rN.
+// The version starts at the last "-" followed by a digit, so names containing digits or
+// other characters keep them (e.g., "clang++-18" and "llvm-18/bin/clang-18" have the
+// identities "clang++" and "llvm-18/bin/clang").
+// SemVer pre-release tags and build metadata are part of the version, so
+// "foo-1.2.3-alpha.1+build.5" and "foo-1.2.3" share the identity "foo".
+// An "N:" epoch before the version is skipped, so "pkg-2:1.2.3-r0" has the identity "pkg".
+// A trailing git commit hash such as "-1a2b3c4" is not a version on its own (see isCommitHash),
+// so "build-1a2b3c4" has no version while "app-1.2.3-1a2b3c4" has the identity "app".
+func Suffix(bs []byte) int {
+	length := len(bs)
+	i := length - 1
+
+	// Handle optional "-rN" revision suffixes (APK convention).
+	if i > 2 && bs[i]-'0' < 10 {
+		for i >= 0 && bs[i]-'0' < 10 {
+			i--
+		}
+
+		if i > 0 && bs[i] == 'r' && bs[i-1] == '-' {
+			i -= 2
+		}
+	}
+
+	// Scan backwards looking for "-N" pattern where N is a digit.
+	for i >= 0 {
+		c := bs[i]
+		if c == '-' && i+1 < length && bs[i+1]-'0' < 10 && !isCommitHash(bs[i+1:]) {
+			return i
+		}
+
+		// Continue scanning through valid version characters.
+		if c-'0' < 10 || c == '.' || c == '-' || c == '+' || (c|32)-'a' < 26 {
+			i--
+			continue
+		}
+
+		// Skip an "N:" epoch prefix (e.g., "pkg-2:1.2.3-r0").
+		if c == ':' && i+1 < length && bs[i+1]-'0' < 10 {
+			if e := epochStart(bs[:i]); e > 0 {
+				return e
+			}
+		}
+
+		break
+	}
+
+	return 0
+}
+
+// isCommitHash reports whether v looks like an abbreviated or full git commit hash rather than
+// a version: 6 to 40 lower case hex digits including at least one letter (so that plain
+// numbers such as "18" or "20250114" are still versions).
+func isCommitHash(v []byte) bool {
+	if len(v) < 6 || len(v) > 40 {
+		return false
+	}
+
+	letters := false
+	for _, c := range v {
+		switch {
+		case c-'a' < 6:
+			letters = true
+		case c-'0' >= 10:
+			return false
+		}
+	}
+
+	return letters
+}
+
+// epochStart returns the position of the "-" before a trailing "-N" epoch in bs, or 0 if there is none.
+func epochStart(bs []byte) int {
+	i := len(bs) - 1
+	for i >= 0 && bs[i]-'0' < 10 {
+		i--
+	}
+
+	if i > 0 && i < len(bs)-1 && bs[i] == '-' {
+		return i
+	}
+
+	return 0
+}
+
+// DateStamp detects date-stamped build names: name-YYYYMMDD[-hhmmss][.ext]
+// Examples:
+// "myapp-nightly-20250114.tar.gz"
+// "snapshot.20250114-235959.img"
+//
+// The date must be delimited by "-" or "." on both sides and be a plausible date
+// (and time) so that hashes and other long numbers in names aren't mistaken for dates.
+//
+// Returns (nameEnd, restStart) where identity = name[:nameEnd] + name[restStart:],
+// or (0, 0) if the pattern is not detected.
+func DateStamp(bs []byte) (int, int) {
+	const size = len("-YYYYMMDD")
+
+	length := len(bs)
+	for i := length - size; i >= 1; i-- {
+		if c := bs[i]; c != '-' && c != '.' {
+			if c == '/' {
+				break
+			}
+			continue
+		}
+
+		e := i + size
+		if bs[i-1] == '/' || !isDate(bs[i+1:e]) || (e < length && bs[e] != '-' && bs[e] != '.') {
+			continue
+		}
+
+		// Include an optional "-hhmmss" time.
+		if t := e + len("-hhmmss"); t <= length && bs[e] == '-' && isTime(bs[e+1:t]) && (t == length || bs[t] == '-' || bs[t] == '.') {
+			e = t
+		}
+
+		return i, e
+	}
+
+	return 0, 0
+}
+
+// isDate reports whether d is a plausible "YYYYMMDD" date.
+func isDate(d []byte) bool {
+	for _, c := range d {
+		if c-'0' >= 10 {
+			return false
+		}
+	}
+
+	num := func(i int) int { return int(d[i]-'0')*10 + int(d[i+1]-'0') }
+	century, month, day := num(0), num(4), num(6)
+
+	return (century == 19 || century == 20) && month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+// isTime reports whether t is a plausible "hhmmss" time.
+func isTime(t []byte) bool {
+	for _, c := range t {
+		if c-'0' >= 10 {
+			return false
+		}
+	}
+
+	num := func(i int) int { return int(t[i]-'0')*10 + int(t[i+1]-'0') }
+
+	return num(0) <= 23 && num(2) <= 59 && num(4) <= 59
 }
@@ -0,0 +1,44 @@
+package files
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// bloomBitsPerKey is the number of filter bits per new file, which with two probes per key
+// gives a false positive rate of about 5%.
+const bloomBitsPerKey = 8
+
+// bloom is a Bloom filter over the exact hashes of new files (see Options.ExactFilter).
+// It is filled concurrently and only read once it is complete. A miss guarantees that no
+// new file has the hash; a hit may be a false positive.
+type bloom struct {
+	bits []atomic.Uint64
+	mask uint64 // Mask for a bit index
+}
+
+// newBloom returns an empty filter sized for n keys.
+func newBloom(n int) *bloom {
+	words := 1 << bits.Len(uint(max(1, n*bloomBitsPerKey/64)-1))
+
+	return &bloom{bits: make([]atomic.Uint64, words), mask: uint64(words)*64 - 1}
+}
+
+// probes returns the two bits of h, taken from its low and high halves.
+func (b *bloom) probes(h uint64) (uint64, uint64) {
+	return h & b.mask, bits.RotateLeft64(h, 32) & b.mask
+}
+
+// add records the hash h.
+func (b *bloom) add(h uint64) {
+	i, j := b.probes(h)
+	b.bits[i>>6].Or(1 << (i & 63))
+	b.bits[j>>6].Or(1 << (j & 63))
+}
+
+// has reports whether h may have been added.
+func (b *bloom) has(h uint64) bool {
+	i, j := b.probes(h)
+
+	return b.bits[i>>6].Load()&(1<<(i&63)) != 0 && b.bits[j>>6].Load()&(1<<(j&63)) != 0
+}
@@ -0,0 +1,101 @@
+package files
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+func TestBloom(t *testing.T) {
+	const n = 10_000
+
+	b := newBloom(n)
+	for i := range n {
+		_, h := identity.Hash(fmt.Sprintf("file%d", i), seed)
+		b.add(h)
+	}
+
+	// No false negatives.
+	for i := range n {
+		if _, h := identity.Hash(fmt.Sprintf("file%d", i), seed); !b.has(h) {
+			t.Fatalf("has(file%d) = false after add", i)
+		}
+	}
+
+	// Misses are rejected most of the time.
+	var hits int
+	for i := range n {
+		if _, h := identity.Hash(fmt.Sprintf("other%d", i), seed); b.has(h) {
+			hits++
+		}
+	}
+	if rate := float64(hits) / n; rate > 0.1 {
+		t.Errorf("false positive rate = %.3f, want <= 0.1", rate)
+	}
+}
+
+func TestDiffWithOptions_ExactFilter(t *testing.T) {
+	for _, gen := range []func(int) ([]string, []string){genMixed, genUnchanged} {
+		old, cur := gen(20_000)
+		want := Diff(old, cur)
+
+		for _, identityFirst := range []bool{false, true} {
+			r := mustDiff(t, old, cur, Options{ExactFilter: true, IdentityFirst: identityFirst})
+			base := mustDiff(t, old, cur, Options{IdentityFirst: identityFirst})
+			if !slices.Equal(r.E, base.E) || r.Summary() != base.Summary() {
+				t.Errorf("IdentityFirst=%v: ExactFilter changed the result", identityFirst)
+			}
+		}
+		if r := mustDiff(t, old, cur, Options{ExactFilter: true}); !slices.Equal(r.E, want.E) {
+			t.Error("ExactFilter differs from Diff")
+		}
+	}
+}
+
+// genUnchanged generates n file pairs where 90% are unchanged, 9% updated,
+// and 1% removed and added (the inverse of TestDiff_LargeScale).
+func genUnchanged(n int) ([]string, []string) {
+	old := make([]string, n)
+	cur := make([]string, n)
+
+	for i := range n {
+		old[i] = fmt.Sprintf("lib/libfoo%d.so.1.0.0", i)
+		switch {
+		case i%100 == 0:
+			old[i] = fmt.Sprintf("old/rm%d.so.1", i)
+			cur[i] = fmt.Sprintf("cur/add%d.so.1", i)
+		case i%10 == 0:
+			cur[i] = fmt.Sprintf("lib/libfoo%d.so.1.1.0", i)
+		default:
+			cur[i] = old[i]
+		}
+	}
+
+	return old, cur
+}
+
+func BenchmarkExactFilter(b *testing.B) {
+	cases := map[string]func(int) ([]string, []string){
+		"unchanged": genUnchanged,
+		"updated":   genData,
+		"mixed":     genMixed,
+	}
+
+	for _, name := range []string{"unchanged", "updated", "mixed"} {
+		old, cur := cases[name](1_000_000)
+		for _, filter := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/filter=%v", name, filter), func(b *testing.B) {
+				opts := &Options{ExactFilter: filter}
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := diff(old, cur, max(1, runtime.GOMAXPROCS(0)), opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package files
+
+import "github.com/egibs/reconcile/internal/identity"
+
+// Classify reports how Diff would reconcile a single pair of files.
+// It returns Unchanged and true if the names are identical, and Updated and true if they
+// differ but share an identity (e.g., "libfoo.so.1" and "libfoo.so.2"). Otherwise the files
+// don't match and it returns Removed and false: diffing the pair would report old as
+// Removed and cur as Added.
+//
+// Classify uses the default identity rules, like Diff; Options such as CaseInsensitive
+// or StripTriplets are not applied.
+func Classify(old, cur string) (Status, bool) {
+	if old == cur {
+		return Unchanged, true
+	}
+
+	if identity.Equal(old, cur) {
+		return Updated, true
+	}
+
+	return Removed, false
+}
+
+// Identity returns the part of a path that is compared when reconciling, with versions
+// removed (e.g., "libfoo.so" for "libfoo.so.1.2.3"), using the default identity rules.
+// Paths that Classify matches have the same Identity.
+func Identity(path string) string {
+	return identity.Identity(path)
+}
@@ -0,0 +1,72 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		want   Status
+		wantOK bool
+	}{
+		{"libfoo.so.1.2.3", "libfoo.so.2.0.0", Updated, true},
+		{"libfoo.so.1", "libbar.so.1", Removed, false},
+		{"foo.1.2.3.so", "foo.4.5.6.so", Updated, true},
+		{"app-1.0.0-r5", "app-2.0.0-r0", Updated, true},
+		{"README.md", "README.md", Unchanged, true},
+		{"a.txt", "b.txt", Removed, false},
+		{"", "", Unchanged, true},
+	}
+
+	for _, c := range cases {
+		got, ok := Classify(c.a, c.b)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("Classify(%q, %q) = (%v, %v), want (%v, %v)", c.a, c.b, got, ok, c.want, c.wantOK)
+		}
+
+		// The classification agrees with diffing the pair.
+		r := Diff([]string{c.a}, []string{c.b})
+		if r.E[0].Status() != got {
+			t.Errorf("Diff(%q, %q) status = %v, Classify = %v", c.a, c.b, r.E[0].Status(), got)
+		}
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+	}{
+		{"libfoo.so.1.2.3", "libfoo.so.2.0.0", "libfoo.so"},                                                      // Soname
+		{"pkg-1.0.Q1abc=.post-install", "pkg-2.0.Q1xyz=.post-install", "pkg.post-install"},                       // Script
+		{"myapp-v1.2.3-linux-amd64", "myapp-v1.3.0-linux-amd64", "myapp-linux-amd64"},                            // GoBinary
+		{"nginx_1.24.0-1_amd64.deb", "nginx_1.25.0-1_amd64.deb", "nginx.deb"},                                    // Deb
+		{"bash-5.2.15-1.fc39.x86_64.rpm", "bash-5.2.26-1.fc39.x86_64.rpm", "bash.rpm"},                           // Rpm
+		{"lodash-4.17.21.tgz", "lodash-4.17.22.tgz", "lodash.tgz"},                                               // Npm
+		{"guava-33.0.0-jre.jar", "guava-33.1.0-jre.jar", "guava.jar"},                                            // Jar
+		{"github.com/foo/bar/@v/v1.2.3.zip", "github.com/foo/bar/@v/v1.3.0.zip", "github.com/foo/bar/@v/v1.zip"}, // GoModule
+		{"main.4f3a9c1b.js", "main.0a1b2c3d.js", "main.js"},                                                      // ContentHash
+		{"zfs-2.2.2.ko.zst", "zfs-2.2.3.ko.zst", "zfs.ko"},                                                       // Kmod
+		{"foo.1.2.3.so", "foo.4.5.6.so", "foo.so"},                                                               // Embedded
+		{"app-1.0.0-r5", "app-2.0.0-r0", "app"},                                                                  // Suffix
+		{"myapp-nightly-20250114.tar.gz", "myapp-nightly-20250115.tar.gz", "myapp-nightly"},                      // DateStamp
+		{"README.md", "README.md", "README.md"},                                                                  // No pattern
+	}
+
+	for _, c := range cases {
+		got, gotB := Identity(c.a), Identity(c.b)
+		if got != c.want || gotB != c.want {
+			t.Errorf("Identity(%q), Identity(%q) = %q, %q, want %q", c.a, c.b, got, gotB, c.want)
+		}
+		if !identity.Equal(c.a, c.b) {
+			t.Errorf("Equal(%q, %q) = false, want true", c.a, c.b)
+		}
+	}
+
+	if Identity("a.txt") == Identity("b.txt") {
+		t.Error("different names share an identity")
+	}
+}
@@ -0,0 +1,207 @@
+package files
+
+import (
+	"slices"
+	"strings"
+)
+
+// CollapsedDir describes a versioned directory whose entire subtree was renamed
+// (e.g., "app-1.0" to "app-2.0") and was collapsed into a single Updated entry.
+type CollapsedDir struct {
+	Old   string // Old directory path (e.g., "opt/app-1.0")
+	New   string // New directory path (e.g., "opt/app-2.0")
+	Files int    // Number of files in the subtree
+	Entry int    // Index of the representative entry in Result.E
+}
+
+// versionedDir splits a directory name such as "app-1.0" or "app_v2.3.1" into its
+// name and reports whether it is versioned. The version must start with a digit
+// (optionally prefixed with "v") and may only contain alphanumerics, '.', '+', and '~'.
+func versionedDir(seg string) (string, bool) {
+	i := strings.LastIndexAny(seg, "-_")
+	if i < 1 || i == len(seg)-1 {
+		return "", false
+	}
+
+	v := seg[i+1:]
+	if v[0] == 'v' && len(v) > 1 {
+		v = v[1:]
+	}
+	if v[0]-'0' >= 10 {
+		return "", false
+	}
+
+	for j := range len(v) {
+		c := v[j]
+		if c-'0' >= 10 && (c|32)-'a' >= 26 && c != '.' && c != '+' && c != '~' {
+			return "", false
+		}
+	}
+
+	return seg[:i], true
+}
+
+// splitVersionedDir finds the outermost versioned directory of path p and returns the
+// directory path, the path with the directory's version removed (the grouping key),
+// and the path of the file relative to the directory.
+func splitVersionedDir(p string) (dir, key, rel string, ok bool) {
+	for start := 0; ; {
+		end := strings.IndexByte(p[start:], '/')
+		if end < 0 {
+			return "", "", "", false
+		}
+		end += start
+
+		if name, ok := versionedDir(p[start:end]); ok {
+			return p[:end], p[:start] + name, p[end+1:], true
+		}
+		start = end + 1
+	}
+}
+
+// dirGroup holds the files of a single versioned directory.
+type dirGroup struct {
+	dir   string
+	files []int    // File indices in ascending order
+	rels  []string // Relative paths aligned with files
+}
+
+// groupVersionedDirs groups files by their outermost versioned directory.
+// Only keys with exactly one directory are kept since a collapse must be unambiguous.
+func groupVersionedDirs(files []string) map[string]*dirGroup {
+	groups := make(map[string]*dirGroup)
+	ambiguous := make(map[string]bool)
+
+	for i, f := range files {
+		dir, key, rel, ok := splitVersionedDir(f)
+		if !ok || ambiguous[key] {
+			continue
+		}
+
+		g := groups[key]
+		switch {
+		case g == nil:
+			g = &dirGroup{dir: dir}
+			groups[key] = g
+		case g.dir != dir:
+			delete(groups, key)
+			ambiguous[key] = true
+			continue
+		}
+
+		g.files = append(g.files, i)
+		g.rels = append(g.rels, rel)
+	}
+
+	return groups
+}
+
+// collapseVersionedDirs replaces the entries of versioned directories whose whole subtree
+// was renamed with a single Updated entry per directory.
+//
+// A directory pair is collapsed when both share the same version-stripped path, each is
+// the only such directory on its side, every old file under it was Removed, every new file
+// under it was Added, and both contain exactly the same relative paths.
+func collapseVersionedDirs(r *Result, old, cur []string) {
+	if r.Count(Removed) == 0 || r.Count(Added) == 0 {
+		return
+	}
+
+	removed := make([]bool, len(old))
+	added := make([]bool, len(cur))
+	for _, e := range r.E {
+		switch e.Status() {
+		case Removed:
+			removed[e.Old()] = true
+		case Added:
+			added[e.New()] = true
+		}
+	}
+
+	curGroups := groupVersionedDirs(cur)
+
+	oldDir := make([]int, len(old)) // Collapsed directory (plus one) of each old file
+	newDir := make([]bool, len(cur))
+	var pairs []uint32 // New file paired with each directory's representative old file
+
+	for key, og := range groupVersionedDirs(old) {
+		ng := curGroups[key]
+		if ng == nil || ng.dir == og.dir || len(ng.files) != len(og.files) {
+			continue
+		}
+		if !all(og.files, removed) || !all(ng.files, added) {
+			continue
+		}
+
+		oldRels := slices.Clone(og.rels)
+		newRels := slices.Clone(ng.rels)
+		slices.Sort(oldRels)
+		slices.Sort(newRels)
+		if !slices.Equal(oldRels, newRels) {
+			continue
+		}
+
+		r.Dirs = append(r.Dirs, CollapsedDir{Old: og.dir, New: ng.dir, Files: len(og.files)})
+		pairs = append(pairs, uint32(ng.files[slices.Index(ng.rels, og.rels[0])])) // #nosec G115
+		for _, i := range og.files {
+			oldDir[i] = len(r.Dirs)
+		}
+		for _, j := range ng.files {
+			newDir[j] = true
+		}
+	}
+
+	if len(r.Dirs) == 0 {
+		return
+	}
+
+	seen := make([]bool, len(r.Dirs))
+	entries := r.E[:0]
+	hashes := r.IdentityHashes[:0]
+	for i, e := range r.E {
+		switch {
+		case e.Old() != null && oldDir[e.Old()] > 0:
+			// The first old file of each directory is its representative.
+			d := oldDir[e.Old()] - 1
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			r.Dirs[d].Entry = len(entries)
+			e = newEntry(e.Old(), pairs[d], Updated)
+		case e.Old() == null && newDir[e.New()]:
+			continue
+		}
+
+		entries = append(entries, e)
+		if r.IdentityHashes != nil {
+			hashes = append(hashes, r.IdentityHashes[i])
+		}
+	}
+
+	var files uint32
+	for _, d := range r.Dirs {
+		files += uint32(d.Files) // #nosec G115
+	}
+
+	r.E = entries
+	if r.IdentityHashes != nil {
+		r.IdentityHashes = hashes
+	}
+	// Directories are found in map order; sort them by their representative entries.
+	slices.SortFunc(r.Dirs, func(a, b CollapsedDir) int { return a.Entry - b.Entry })
+	r.C[Removed].Add(-files)
+	r.C[Added].Add(-files)
+	r.C[Updated].Add(uint32(len(r.Dirs))) // #nosec G115
+}
+
+// all reports whether every file is set in marks.
+func all(files []int, marks []bool) bool {
+	for _, i := range files {
+		if !marks[i] {
+			return false
+		}
+	}
+
+	return true
+}
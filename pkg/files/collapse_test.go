@@ -0,0 +1,80 @@
+package files
+
+import "testing"
+
+func TestVersionedDir(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"app-1.0", "app", true},
+		{"app_v2.3.1", "app", true},
+		{"python-3.12.1+build1", "python", true},
+		{"my-app-10", "my-app", true},
+		{"app-beta", "", false},
+		{"app-", "", false},
+		{"-1.0", "", false},
+		{"app-1.0/x", "", false},
+		{"lib", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := versionedDir(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("versionedDir(%q) = (%q, %v), want (%q, %v)", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDiffWithOptions_CollapseVersionedDirs(t *testing.T) {
+	old := []string{"opt/app-1.0/bin/app", "opt/app-1.0/lib/a.txt", "etc/app.conf", "opt/tool-1/x"}
+	cur := []string{"etc/app.conf", "opt/app-2.0/lib/a.txt", "opt/app-2.0/bin/app", "opt/tool-2/y"}
+
+	r := mustDiff(t, old, cur, Options{})
+	if r.Count(Removed) != 3 || r.Count(Added) != 3 {
+		t.Fatalf("without collapse: removed=%d added=%d, want 3 and 3", r.Count(Removed), r.Count(Added))
+	}
+
+	r = mustDiff(t, old, cur, Options{CollapseVersionedDirs: true, IdentityHashes: true})
+
+	// "opt/tool-*" differs in contents so it is not collapsed.
+	want := []Entry{
+		newEntry(0, 2, Updated),
+		newEntry(2, 0, Unchanged),
+		newEntry(3, null, Removed),
+		newEntry(null, 3, Added),
+	}
+	if len(r.E) != len(want) {
+		t.Fatalf("entries = %v, want %v", r.E, want)
+	}
+	for i, e := range r.E {
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+	if len(r.IdentityHashes) != len(r.E) {
+		t.Errorf("len(IdentityHashes) = %d, want %d", len(r.IdentityHashes), len(r.E))
+	}
+
+	if r.Count(Updated) != 1 || r.Count(Unchanged) != 1 || r.Count(Removed) != 1 || r.Count(Added) != 1 {
+		t.Errorf("counts: updated=%d unchanged=%d removed=%d added=%d, want 1 each",
+			r.Count(Updated), r.Count(Unchanged), r.Count(Removed), r.Count(Added))
+	}
+
+	wantDir := CollapsedDir{Old: "opt/app-1.0", New: "opt/app-2.0", Files: 2, Entry: 0}
+	if len(r.Dirs) != 1 || r.Dirs[0] != wantDir {
+		t.Errorf("Dirs = %+v, want [%+v]", r.Dirs, wantDir)
+	}
+}
+
+func TestDiffWithOptions_CollapseVersionedDirsAmbiguous(t *testing.T) {
+	// Two new versions of the same directory cannot be collapsed unambiguously.
+	old := []string{"app-1.0/a"}
+	cur := []string{"app-2.0/a", "app-3.0/a"}
+
+	r := mustDiff(t, old, cur, Options{CollapseVersionedDirs: true})
+	if len(r.Dirs) != 0 || r.Count(Removed) != 1 || r.Count(Added) != 2 {
+		t.Errorf("Dirs = %+v removed=%d added=%d, want none, 1, and 2", r.Dirs, r.Count(Removed), r.Count(Added))
+	}
+}
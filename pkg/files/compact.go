@@ -0,0 +1,189 @@
+package files
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// compactVersion identifies the compact encoding layout written by MarshalCompact.
+const compactVersion = 1
+
+// ErrInvalidCompact is returned by UnmarshalCompact for malformed input.
+var ErrInvalidCompact = errors.New("invalid compact result")
+
+// MarshalCompact writes a compact binary encoding of the result to w.
+//
+// The encoding consists of a version byte, the status counts, and the number of
+// entries (all as unsigned varints), followed by the entry stream. Each entry starts
+// with a varint whose low nibble holds the status and whose remaining bits hold the
+// zigzag-encoded delta of the first present index (Old, or New for Added entries)
+// from the previous one. Entries with both indices follow this with the zigzag delta
+// of New. Since indices are typically monotonic, most entries encode in 1-2 bytes.
+// IdentityHashes are not included.
+func (r *Result) MarshalCompact(w io.Writer) error {
+	buf := make([]byte, 0, 64<<10)
+	buf = append(buf, compactVersion)
+
+	for s := range numStatuses {
+		buf = binary.AppendUvarint(buf, uint64(r.C[s].Load()))
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(r.E)))
+
+	var prevOld, prevNew int64 = -1, -1
+
+	for _, e := range r.E {
+		s := e.Status()
+
+		var delta int64
+		switch s {
+		case Added:
+			delta = int64(e.New()) - prevNew - 1
+			prevNew = int64(e.New())
+		default:
+			delta = int64(e.Old()) - prevOld - 1
+			prevOld = int64(e.Old())
+		}
+		buf = binary.AppendUvarint(buf, zigzag(delta)<<4|uint64(s))
+
+		if s != Added && s != Removed {
+			buf = binary.AppendUvarint(buf, zigzag(int64(e.New())-prevNew-1))
+			prevNew = int64(e.New())
+		}
+
+		if len(buf) >= cap(buf)-2*binary.MaxVarintLen64 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// UnmarshalCompact replaces the contents of the result with a result encoded by MarshalCompact.
+func (r *Result) UnmarshalCompact(rd io.Reader) error {
+	br, ok := rd.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(rd)
+	}
+
+	version, err := br.ReadByte()
+	if err != nil {
+		return compactErr(err)
+	}
+	if version != compactVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidCompact, version)
+	}
+
+	var counts [numStatuses]uint64
+	for s := range numStatuses {
+		if counts[s], err = binary.ReadUvarint(br); err != nil {
+			return compactErr(err)
+		}
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return compactErr(err)
+	}
+
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total != n {
+		return fmt.Errorf("%w: counts total %d does not match %d entries", ErrInvalidCompact, total, n)
+	}
+
+	entries := make([]Entry, 0, min(n, 1<<20))
+	var seen [numStatuses]uint64
+	var prevOld, prevNew int64 = -1, -1
+
+	for range n {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return compactErr(err)
+		}
+
+		s := Status(v & 0xF)
+		if int(s) >= numStatuses {
+			return fmt.Errorf("%w: unknown status %d", ErrInvalidCompact, s)
+		}
+		seen[s]++
+
+		oldIdx, newIdx := null, null
+		first := prevOld
+		if s == Added {
+			first = prevNew
+		}
+
+		limit := null
+		if s == Added {
+			limit = maxNewFiles
+		}
+
+		idx, err := compactIndex(first, unzigzag(v>>4), limit)
+		if err != nil {
+			return err
+		}
+
+		if s == Added {
+			newIdx, prevNew = idx, int64(idx)
+		} else {
+			oldIdx, prevOld = idx, int64(idx)
+		}
+
+		if s != Added && s != Removed {
+			d, err := binary.ReadUvarint(br)
+			if err != nil {
+				return compactErr(err)
+			}
+			if newIdx, err = compactIndex(prevNew, unzigzag(d), maxNewFiles); err != nil {
+				return err
+			}
+			prevNew = int64(newIdx)
+		}
+
+		entries = append(entries, newEntry(oldIdx, newIdx, s))
+	}
+
+	if seen != counts {
+		return fmt.Errorf("%w: status counts do not match entries", ErrInvalidCompact)
+	}
+
+	r.E = entries
+	r.IdentityHashes = nil
+	for s := range numStatuses {
+		r.C[s].Store(uint32(counts[s])) // #nosec G115
+	}
+
+	return nil
+}
+
+// compactIndex applies a delta to the previous index and validates that the result is below limit.
+func compactIndex(prev, delta int64, limit uint32) (uint32, error) {
+	idx := prev + 1 + delta
+	if idx < 0 || idx >= int64(limit) {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrInvalidCompact, idx)
+	}
+
+	return uint32(idx), nil
+}
+
+// compactErr wraps a read error, treating a premature EOF as malformed input.
+func compactErr(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidCompact, err)
+}
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) } // #nosec G115
+
+func unzigzag(v uint64) int64 { return int64(v>>1) ^ -int64(v&1) } // #nosec G115
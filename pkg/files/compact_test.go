@@ -0,0 +1,78 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestResult_CompactRoundTrip(t *testing.T) {
+	old, cur := genMixed(10_000)
+
+	for _, r := range []*Result{Diff(old, cur), Diff(nil, cur), Diff(old, nil), Diff(nil, nil)} {
+		var buf bytes.Buffer
+		if err := r.MarshalCompact(&buf); err != nil {
+			t.Fatalf("MarshalCompact() error = %v", err)
+		}
+
+		var got Result
+		if err := got.UnmarshalCompact(&buf); err != nil {
+			t.Fatalf("UnmarshalCompact() error = %v", err)
+		}
+
+		if !slices.Equal(got.E, r.E) {
+			t.Error("entries differ after round trip")
+		}
+		for s := range numStatuses {
+			if got.C[s].Load() != r.C[s].Load() {
+				t.Errorf("count %d = %d, want %d", s, got.C[s].Load(), r.C[s].Load())
+			}
+		}
+	}
+}
+
+func TestResult_CompactSize(t *testing.T) {
+	old, cur := genMixed(10_000)
+	r := Diff(old, cur)
+
+	var buf bytes.Buffer
+	if err := r.MarshalCompact(&buf); err != nil {
+		t.Fatalf("MarshalCompact() error = %v", err)
+	}
+
+	js, err := json.Marshal(r.E)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	t.Logf("compact=%d bytes json=%d bytes (%.1fx)", buf.Len(), len(js), float64(len(js))/float64(buf.Len()))
+
+	if buf.Len()*8 > len(js) {
+		t.Errorf("compact encoding (%d bytes) is not at least 8x smaller than JSON (%d bytes)", buf.Len(), len(js))
+	}
+}
+
+func TestResult_CompactInvalid(t *testing.T) {
+	r := Diff([]string{"a.so.1", "b.txt"}, []string{"a.so.2", "c.txt"})
+
+	var buf bytes.Buffer
+	if err := r.MarshalCompact(&buf); err != nil {
+		t.Fatalf("MarshalCompact() error = %v", err)
+	}
+	valid := buf.Bytes()
+
+	cases := map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{9}, valid[1:]...),
+		"truncated": valid[:len(valid)-1],
+	}
+
+	for name, data := range cases {
+		var got Result
+		if err := got.UnmarshalCompact(bytes.NewReader(data)); !errors.Is(err, ErrInvalidCompact) {
+			t.Errorf("%s: UnmarshalCompact() error = %v, want ErrInvalidCompact", name, err)
+		}
+	}
+}
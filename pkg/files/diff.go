@@ -1,8 +1,12 @@
 package files
 
 import (
+	"context"
+	"fmt"
 	"hash/maphash"
+	"path"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 
@@ -10,47 +14,231 @@ import (
 )
 
 const (
-	null      uint32 = 0xFFFFFFFF // Sentinel value for unset file indices
-	numShards        = 1 << shardBits
-	shardBits        = 8
-	shardMask uint64 = numShards - 1 // Mask for extracting a shard's index from a given hash
+	null uint32 = 0xFFFFFFFF // Sentinel value for unset file indices
+
+	stealBlock       = 1024     // Number of old files claimed at a time with Options.WorkStealing
+	progressInterval = 64 << 10 // Number of old files reconciled between calls to Options.Progress
+	mergeParallelMin = 64 << 10 // Number of entries from which merge copies them in parallel
 )
 
 // This seed is initialized once at package load time for consistent hashing
 // and ensures deterministic results across calls.
 var seed = maphash.MakeSeed()
 
-// shard represents a single partition of the O(1) hash table.
-type shard struct {
-	sync.Mutex
-	m map[uint64]uint32 // map containing matches (exact keys use hash|hash.ExactFlag; identity keys just use hash)
-}
-
 // Diff compares two file lists and returns a Result containing all reconciliation entries.
+// It is equivalent to DiffN(old, cur, runtime.GOMAXPROCS(0)).
+// Diff panics if cur holds more than 2^29-1 files (see Entry); DiffContext and
+// DiffWithOptions return ErrTooManyEntries instead.
 func Diff(old, cur []string) *Result {
 	return diffP(old, cur, max(1, runtime.GOMAXPROCS(0)))
 }
 
+// DiffN is like Diff but uses the given number of workers, which is useful to cap
+// concurrency when running many diffs in parallel. Worker counts below 1 are treated as 1.
+// The result does not depend on the number of workers. Like Diff, DiffN panics if cur
+// holds more than 2^29-1 files; DiffWithOptions with Options.Workers returns ErrTooManyEntries instead.
+func DiffN(old, cur []string, workers int) *Result {
+	return diffP(old, cur, max(1, workers))
+}
+
 // diffP compares two file lists with an explicit worker count.
 func diffP(old, cur []string, workers int) *Result {
+	// Without Options.MaxEntries the diff only fails when cur exceeds maxNewFiles.
+	r, err := diff(old, cur, workers, &Options{})
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// DiffContext is like Diff but stops early and returns ctx.Err() when ctx is cancelled,
+// and returns ErrTooManyEntries rather than panicking when cur holds more than 2^29-1 files.
+// Workers check ctx periodically, so cancellation is observed promptly and no goroutines
+// are left running once DiffContext returns.
+func DiffContext(ctx context.Context, old, cur []string) (*Result, error) {
+	return diffContext(ctx, old, cur, max(1, runtime.GOMAXPROCS(0)), &Options{}, nil)
+}
+
+// diff compares two file lists with an explicit worker count and options.
+// The file lists are expected to already be normalized by the options.
+func diff(old, cur []string, workers int, opts *Options) (*Result, error) {
+	return diffContext(context.Background(), old, cur, workers, opts, nil)
+}
+
+// diffContext implements diff, stopping early when ctx is done.
+// The lookup table and match bits are taken from sc when it is set (see Reconciler).
+func diffContext(ctx context.Context, old, cur []string, workers int, opts *Options, sc *scratch) (*Result, error) {
 	oldFiles, newFiles := len(old), len(cur)
 	if oldFiles|newFiles == 0 {
-		return &Result{}
+		return &Result{}, nil
+	}
+
+	if newFiles > maxNewFiles {
+		return nil, fmt.Errorf("%w: %d new files exceed the limit of %d", ErrTooManyEntries, newFiles, maxNewFiles)
+	}
+
+	// Every old file produces an entry and every new file beyond the old count must be
+	// an addition, so the larger list is a lower bound on the number of entries.
+	if err := opts.checkEntries(max(oldFiles, newFiles)); err != nil {
+		return nil, err
+	}
+
+	// When one side is empty every file is either Added or Removed,
+	// so hashing and building the lookup table can be skipped entirely
+	// (unless identity hashes were requested).
+	if oldFiles == 0 && !opts.IdentityHashes {
+		return oneSided(newFiles, Added), nil
+	}
+	if newFiles == 0 && !opts.IdentityHashes {
+		return oneSided(oldFiles, Removed), nil
 	}
 
 	// Calculate hashes for both the old and new files.
-	oldHashes, oldEntries := identity.HashAll(old, workers, seed)
-	curHashes, curEntries := identity.HashAll(cur, workers, seed)
+	cfg := opts.config()
+	seed := opts.seed()
+	oldHashes, oldEntries, err := cfg.HashAllContext(ctx, old, workers, seed)
+	if err != nil {
+		return nil, err
+	}
+	curHashes, curEntries, err := cfg.HashAllContext(ctx, cur, workers, seed)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build a map of all new files for O(1) lookups.
-	// Exact entry keys use a file's hash OR'd with the exact flag (hash | exactFlag).
-	// Identity entry keys just use a file's hash.
-	// Both entry values are the file's index.
-	// Using a high bit flag allows for both entries to exist in the same map.
-	shards := make([]shard, numShards)
-	expected := max(16, newFiles/numShards*2)
-	for i := range shards {
-		shards[i].m = make(map[uint64]uint32, expected)
+	done := ctx.Done()
+	tbl, filter := buildTable(curHashes, curEntries, workers, opts, done, sc)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reconcile the old and new file lists.
+	// Check for exact matches first and identity matches second (or the reverse with
+	// Options.IdentityFirst); fall back to removal if there are no exact or identity matches.
+	// Bitwise operations are used to track matches to ensure that a new file only matches one old file.
+	rc := &reconciler{
+		old:        old,
+		cur:        cur,
+		oldHashes:  oldHashes,
+		oldEntries: oldEntries,
+		table:      tbl,
+		filter:     filter,
+		cfg:        cfg,
+		matches:    sc.bits((newFiles + 63) >> 6), // One bit per new file
+		owners:     sc.owners(newFiles),
+		opts:       opts,
+		content:    opts.hasContent(oldFiles, newFiles),
+		trusted:    opts.trusted(oldFiles, newFiles),
+		done:       done,
+	}
+	if opts.Stats {
+		rc.stats = &counters{}
+	}
+
+	// Claim the new files of trusted pairs up front so that they are never matched against other old files.
+	for _, j := range rc.trusted {
+		if j != null {
+			identity.TryMark(rc.matches, j)
+		}
+	}
+
+	// Workers look up the preferred match of each old file in parallel without claiming it.
+	var results [][]Entry // Per-range reconciliation results in old file order
+
+	if opts.WorkStealing {
+		results = rc.steal(oldFiles, workers)
+	} else {
+		results = make([][]Entry, workers)
+		chunk := max(1, (oldFiles+workers-1)/workers)
+
+		var wg sync.WaitGroup
+
+		for worker := range workers {
+			low := worker * chunk
+			if low >= oldFiles {
+				break
+			}
+
+			high := min(low+chunk, oldFiles)
+
+			wg.Go(func() {
+				results[worker] = rc.reconcile(low, high)
+			})
+		}
+		wg.Wait()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Claim the matched new files as if in old file order so that the result doesn't depend on
+	// the number or scheduling of workers. Statuses exclude Additions which are handled separately.
+	counts := rc.claim(results, workers)
+
+	// Each new file that was not matched becomes an addition, so the entry count is known
+	// before the per-worker addition slices are allocated. Renames pair a removal with an
+	// addition, so only a lower bound is known until they have been paired.
+	removed := 0
+	for _, c := range counts {
+		removed += int(c[Removed])
+	}
+	entries := newFiles + removed // One per old file plus the unmatched new files
+
+	detectRenames := rc.content && opts.DetectRenames
+	if detectRenames {
+		entries -= min(removed, newFiles-oldFiles+removed)
+	}
+	if err := opts.checkEntries(entries); err != nil {
+		return nil, err
+	}
+
+	// Pair the remaining removals and additions by content.
+	var renamed uint32
+	if detectRenames {
+		renamed = rc.renames(results)
+		if err := opts.checkEntries(newFiles + removed - int(renamed)); err != nil {
+			return nil, err
+		}
+	}
+	// Check matched file bits for unmatched files and treat them as additions.
+	additions := rc.additions(workers)
+
+	// Deterministically merge all of the reconciliation results
+	// and additions into a final result type.
+	result := merge(results, additions, counts, workers)
+	result.C[Removed].Add(-renamed)
+	result.C[Renamed].Add(renamed)
+
+	if rc.stats != nil {
+		result.Stats = rc.stats.stats()
+	}
+
+	if opts.IdentityHashes {
+		result.IdentityHashes = make([]uint64, len(result.E))
+		for i, e := range result.E {
+			if e.Old() != null {
+				result.IdentityHashes[i] = oldHashes[e.Old()]
+			} else {
+				result.IdentityHashes[i] = curHashes[e.New()]
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// buildTable builds the table of new files used for O(1) lookups (see table), along with
+// a filter over their exact hashes when Options.ExactFilter is set (or nil otherwise).
+// Workers stop early when done is closed. The table of sc is cleared and reused when sc is set.
+func buildTable(curHashes, curEntries []uint64, workers int, opts *Options, done <-chan struct{}, sc *scratch) (*table, *bloom) {
+	newFiles := len(curHashes)
+	tbl := sc.tableFor(2 * newFiles) // An identity and an exact key per file
+
+	var filter *bloom
+	if opts.ExactFilter {
+		filter = newBloom(newFiles)
 	}
 
 	chunk := max(1, (newFiles+workers-1)/workers)
@@ -67,85 +255,338 @@ func diffP(old, cur []string, workers int) *Result {
 
 		wg.Go(func() {
 			for i := low; i < high; i++ {
-				shard := &shards[curHashes[i]&shardMask]
-				fileIdx := uint32(i) // #nosec G115
-				idKey := curHashes[i]
-				exKey := curEntries[i] | identity.ExactFlag
+				if done != nil && (i-low)%identity.CheckInterval == 0 && identity.Cancelled(done) {
+					return
+				}
 
-				shard.Lock()
-				// Only store the first identity match (handling deduplication).
-				if _, ok := shard.m[idKey]; !ok {
-					shard.m[idKey] = fileIdx
+				tbl.add(curHashes[i], curEntries[i], uint32(i), opts.TieBreak) // #nosec G115
+				if filter != nil {
+					filter.add(curEntries[i])
 				}
+			}
+		})
+	}
+	wg.Wait()
+
+	return tbl, filter
+}
+
+// merge concatenates the per-range reconciliation results and additions into a Result
+// and sums the per-worker counts. Large results are copied into place by up to workers
+// goroutines, each filling an equal range of Result.E from the slices overlapping it.
+func merge(results, additions [][]Entry, counts [][numStatuses]uint32, workers int) *Result {
+	sources := slices.Concat(results, additions)
+	offsets := make([]int, len(sources)+1) // Position of each source within Result.E
+	for k, src := range sources {
+		offsets[k+1] = offsets[k] + len(src)
+	}
+	total := offsets[len(sources)]
+
+	result := &Result{E: make([]Entry, total)}
+
+	// fill copies the entries belonging at positions [low, high).
+	fill := func(low, high int) {
+		k, _ := slices.BinarySearch(offsets[1:], low+1) // First source ending after low
+		for pos := low; pos < high; k++ {
+			pos += copy(result.E[pos:high], sources[k][pos-offsets[k]:])
+		}
+	}
+
+	if workers < 2 || total < mergeParallelMin {
+		fill(0, total)
+	} else {
+		chunk := (total + workers - 1) / workers
 
-				// Always store exact matches (last occurrence takes precedence).
-				shard.m[exKey] = fileIdx
-				shard.Unlock()
+		var wg sync.WaitGroup
+
+		for low := 0; low < total; low += chunk {
+			wg.Go(func() {
+				fill(low, min(low+chunk, total))
+			})
+		}
+		wg.Wait()
+	}
+
+	// Additions are handled separately so their per-worker count is always zero.
+	for _, c := range counts {
+		for status := range numStatuses {
+			result.C[status].Add(c[status])
+		}
+	}
+
+	for _, entries := range additions {
+		result.C[Added].Add(uint32(len(entries))) // #nosec G115
+	}
+
+	return result
+}
+
+// forEach calls fn for each of [0, n) using up to workers goroutines.
+func forEach(n, workers int, fn func(int)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for range min(workers, n) {
+		wg.Go(func() {
+			for {
+				k := int(next.Add(1) - 1)
+				if k >= n {
+					return
+				}
+				fn(k)
 			}
 		})
 	}
 	wg.Wait()
+}
 
-	// Reconcile the old and new file lists.
-	// Check for exact matches first and identity matches second; fall back to removal
-	// if there are no exact or identity matches.
-	// Bitwise operations are used to track matches to ensure that a new file only matches one old file.
-	matches := make([]atomic.Uint64, (newFiles+63)>>6) // One bit per new file
-	results := make([][]Entry, workers)                // Per-worker reconciliation results
-	counts := make([][3]uint32, workers)               // Per-worker statuses excluding Additions which are handled separately
+// storeMin stores v in a unless a already holds a smaller non-zero value.
+func storeMin(a *atomic.Uint32, v uint32) {
+	for {
+		cur := a.Load()
+		if cur != 0 && cur <= v || a.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
 
-	chunk = max(1, (oldFiles+workers-1)/workers)
+// oneSided builds a Result where all n files share the same Added or Removed status.
+func oneSided(n int, s Status) *Result {
+	result := &Result{E: make([]Entry, n)}
 
-	for worker := range workers {
-		low := worker * chunk
-		if low >= oldFiles {
+	for i := range result.E {
+		idx := uint32(i) // #nosec G115
+		if s == Added {
+			result.E[i] = newEntry(null, idx, s)
+		} else {
+			result.E[i] = newEntry(idx, null, s)
+		}
+	}
+	result.C[s].Store(uint32(n)) // #nosec G115
+
+	return result
+}
+
+// reconciler holds the state shared by workers while matching old files against new files.
+type reconciler struct {
+	old, cur   []string
+	offset     int // Position of old within the whole old file list (see DiffReaders)
+	oldHashes  []uint64
+	oldEntries []uint64
+	table      *table
+	filter     *bloom // Filter over new exact hashes (nil unless Options.ExactFilter is set)
+	cfg        *identity.Config
+	matches    []atomic.Uint64
+	owners     []atomic.Uint32 // First old file (plus one) claiming each new file, or zero (nil outside of Diff)
+	displaced  []uint32        // Old files whose new file was taken by an earlier old file during claim
+	opts       *Options
+	content    bool
+	trusted    []uint32        // Trusted new file index for each old file (see Options.TrustedPairs)
+	done       <-chan struct{} // Closed when reconciliation should stop early (nil if it never does)
+	processed  atomic.Int64    // Number of old files reconciled so far (only tracked for Options.Progress)
+	stats      *counters       // Lookup counters (nil unless Options.Stats is set)
+}
+
+// reconcile finds the preferred match of old files [low, high) (see candidate).
+// It stops early, returning the entries so far, when rc.done is closed.
+func (rc *reconciler) reconcile(low, high int) []Entry {
+	entries := make([]Entry, 0, high-low)
+	if rc.opts.Progress == nil {
+		return rc.reconcileRange(entries, low, high)
+	}
+
+	for l := low; l < high; l += progressInterval {
+		h := min(l+progressInterval, high)
+		n := len(entries)
+		entries = rc.reconcileRange(entries, l, h)
+		rc.progress(len(entries) - n)
+
+		if len(entries)-n < h-l {
 			break
 		}
+	}
 
-		high := min(low+chunk, oldFiles)
+	return entries
+}
 
-		wg.Go(func() {
-			entries := make([]Entry, 0, high-low)
-			var status [3]uint32
+// progress records that n more old files were reconciled and calls Options.Progress
+// whenever the running total crosses a multiple of progressInterval or reaches the
+// number of old files, so that callbacks are batched regardless of the range sizes.
+func (rc *reconciler) progress(n int) {
+	if n == 0 {
+		return
+	}
 
-			for i := low; i < high; i++ {
-				fileIdx := uint32(i) // #nosec G115
-				shard := &shards[oldHashes[i]&shardMask]
-				m := shard.m
-
-				// Check for exact matches first.
-				if exMatch, ok := m[oldEntries[i]|identity.ExactFlag]; ok {
-					if old[i] == cur[exMatch] && identity.TryMark(matches, exMatch) {
-						entries = append(entries, Entry{fileIdx, exMatch, uint32(Unchanged)})
-						status[Unchanged]++
-						continue
-					}
+	done, total := int(rc.processed.Add(int64(n))), len(rc.old)
+	if done/progressInterval != (done-n)/progressInterval || done == total {
+		rc.opts.Progress(done, total)
+	}
+}
+
+// reconcileRange appends the entries for old files [low, high) to entries.
+// It stops early when rc.done is closed.
+func (rc *reconciler) reconcileRange(entries []Entry, low, high int) []Entry {
+	for i := low; i < high; i++ {
+		if rc.done != nil && (i-low)%identity.CheckInterval == 0 && identity.Cancelled(rc.done) {
+			break
+		}
+
+		match, s := rc.candidate(i)
+		entries = append(entries, newEntry(uint32(i), match, s)) // #nosec G115
+	}
+
+	return entries
+}
+
+// claim marks the new file of each entry as matched and returns the count of each status for
+// each range of results. When several old files prefer the same new file, the first one in old
+// file order gets it and the others are matched again with match, in old file order. This gives
+// every entry the match it would get if all old files were reconciled one at a time, so the result
+// is the same for any number of workers, while only the conflicting entries are matched serially.
+func (rc *reconciler) claim(results [][]Entry, workers int) [][numStatuses]uint32 {
+	counts := make([][numStatuses]uint32, len(results))
+
+	// Find the first old file preferring each new file.
+	forEach(len(results), workers, func(b int) {
+		for _, e := range results[b] {
+			if i, j := e.Old(), e.New(); j != null && !rc.isTrusted(i, j) {
+				storeMin(&rc.owners[j], i+1)
+			}
+		}
+	})
+
+	// Claim the new file of each first old file and collect the others.
+	pending := make([][]uint32, len(results))
+	forEach(len(results), workers, func(b int) {
+		for _, e := range results[b] {
+			counts[b][e.Status()]++
+
+			i, j := e.Old(), e.New()
+			if j == null || rc.isTrusted(i, j) {
+				continue
+			}
+			if rc.owners[j].Load() == i+1 {
+				if identity.TryMark(rc.matches, j) {
+					continue
 				}
+				rc.owners[j].Store(0) // Claimed up front by a trusted pair
+			}
+			pending[b] = append(pending[b], i)
+		}
+	})
+
+	rc.rematch(results, counts, slices.Concat(pending...))
+
+	return counts
+}
+
+// rematch matches the given old files again in old file order, updating their entries and the
+// counts of their ranges. An old file may take the new file claimed by a later old file, which
+// is then matched again in turn. The lookups made again are not counted in Stats.
+func (rc *reconciler) rematch(results [][]Entry, counts [][numStatuses]uint32, pending []uint32) {
+	if len(pending) == 0 {
+		return
+	}
+
+	stats := rc.stats
+	rc.stats = nil
+	defer func() { rc.stats = stats }()
+
+	ends := make([]uint32, len(results)) // Position after the last old file of each range
+	var n uint32
+	for b, entries := range results {
+		n += uint32(len(entries)) // #nosec G115
+		ends[b] = n
+	}
 
-				// Check for identity matches second.
-				if idMatch, ok := m[oldHashes[i]]; ok {
-					if !identity.IsMarked(matches, idMatch) && identity.Equal(old[i], cur[idMatch]) && identity.TryMark(matches, idMatch) {
-						entries = append(entries, Entry{fileIdx, idMatch, uint32(Updated)})
-						status[Updated]++
-						continue
-					}
+	for k := 0; k < len(pending); k++ {
+		i := pending[k]
+		b, _ := slices.BinarySearch(ends, i+1) // First range ending after i
+		e := &results[b][len(results[b])-int(ends[b]-i)]
+
+		j, s := rc.match(int(i))
+		counts[b][e.Status()]--
+		counts[b][s]++
+		*e = newEntry(i, j, s)
+
+		for _, d := range rc.displaced {
+			pos, _ := slices.BinarySearch(pending[k+1:], d)
+			pending = slices.Insert(pending, k+1+pos, d)
+		}
+		rc.displaced = rc.displaced[:0]
+	}
+}
+
+// free reports whether new file j can be matched to old file i: it is unclaimed or, while claim
+// resolves conflicts, it is only claimed by a later old file.
+func (rc *reconciler) free(i int, j uint32) bool {
+	if !identity.IsMarked(rc.matches, j) {
+		return true
+	}
+
+	return rc.owners != nil && rc.owners[j].Load() > uint32(i)+1 // #nosec G115
+}
+
+// take claims new file j for old file i if it is free, recording the later old file it was
+// taken from, if any, in rc.displaced.
+func (rc *reconciler) take(i int, j uint32) bool {
+	if rc.owners == nil {
+		return identity.TryMark(rc.matches, j)
+	}
+	if !rc.free(i, j) {
+		return false
+	}
+
+	if !identity.TryMark(rc.matches, j) {
+		rc.displaced = append(rc.displaced, rc.owners[j].Load()-1)
+	}
+	rc.owners[j].Store(uint32(i) + 1) // #nosec G115
+
+	return true
+}
+
+// isTrusted reports whether old file i and new file j are a trusted pair,
+// whose new file is claimed up front.
+func (rc *reconciler) isTrusted(i, j uint32) bool {
+	return rc.trusted != nil && rc.trusted[i] == j
+}
+
+// steal reconciles all old files with workers claiming fixed-size blocks from a shared
+// counter, so that workers which finish early pick up the remaining work instead of idling.
+// The results of each block are returned in old file order.
+func (rc *reconciler) steal(oldFiles, workers int) [][]Entry {
+	blocks := (oldFiles + stealBlock - 1) / stealBlock
+	results := make([][]Entry, blocks)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for range min(workers, blocks) {
+		wg.Go(func() {
+			for {
+				b := int(next.Add(1) - 1)
+				if b >= blocks {
+					return
 				}
 
-				// Fall back to removal if there are no matches.
-				entries = append(entries, Entry{fileIdx, null, uint32(Removed)})
-				status[Removed]++
+				low := b * stealBlock
+				results[b] = rc.reconcile(low, min(low+stealBlock, oldFiles))
 			}
-
-			results[worker] = entries
-			counts[worker] = status
 		})
 	}
 	wg.Wait()
 
-	// Check matched file bits for unmatched files and treat them as additions.
+	return results
+}
+
+// additions returns the new files which were not matched to any old file as Added entries,
+// split into one range per worker in new file order.
+func (rc *reconciler) additions(workers int) [][]Entry {
+	newFiles := len(rc.cur)
 	additions := make([][]Entry, workers)
+	chunk := max(1, (newFiles+workers-1)/workers)
 
-	chunk = max(1, (newFiles+workers-1)/workers)
+	var wg sync.WaitGroup
 
 	for worker := range workers {
 		low := worker * chunk
@@ -161,8 +602,8 @@ func diffP(old, cur []string, workers int) *Result {
 			for i := low; i < high; i++ {
 				fileIdx := uint32(i) // #nosec G115
 
-				if !identity.IsMarked(matches, fileIdx) {
-					entries = append(entries, Entry{null, fileIdx, uint32(Added)})
+				if !identity.IsMarked(rc.matches, fileIdx) {
+					entries = append(entries, newEntry(null, fileIdx, Added))
 				}
 			}
 
@@ -171,32 +612,174 @@ func diffP(old, cur []string, workers int) *Result {
 	}
 	wg.Wait()
 
-	// Deterministically merge all of the reconciliation results
-	// and additions into a final result type.
-	var total int
-	for _, r := range results {
-		total += len(r)
+	return additions
+}
+
+// match finds the new file matching old file i, claiming it, and returns its index and status.
+// A null index and Removed are returned when there is no unclaimed match.
+func (rc *reconciler) match(i int) (uint32, Status) {
+	if rc.trusted != nil && rc.trusted[i] != null {
+		return rc.trusted[i], rc.same(i, rc.trusted[i])
 	}
-	for _, a := range additions {
-		total += len(a)
+
+	identityFirst := rc.opts.IdentityFirst
+	for pass := range 2 {
+		j, ok := null, false
+		if (pass == 0) != identityFirst {
+			// Fall back to the table when the new file at the same index was already claimed.
+			if j, ok = rc.exact(i); ok && !rc.free(i, j) {
+				j, ok = rc.exactLookup(i)
+			}
+		} else {
+			// Skip comparing identities when the new file was already claimed.
+			j, ok = rc.table.get(rc.oldHashes[i])
+			ok = ok && rc.free(i, j) && rc.equal(i, j)
+		}
+
+		if ok && rc.take(i, j) {
+			return j, rc.status(i, j)
+		}
 	}
 
-	result := &Result{E: make([]Entry, 0, total)}
+	return null, Removed
+}
+
+// candidate is like match but doesn't consider or claim matched new files,
+// returning the preferred match of old file i for claim to resolve.
+func (rc *reconciler) candidate(i int) (uint32, Status) {
+	if rc.trusted != nil && rc.trusted[i] != null {
+		return rc.trusted[i], rc.same(i, rc.trusted[i])
+	}
 
-	for worker, entries := range results {
-		result.E = append(result.E, entries...)
-		// Only iterate over the Unchanged, Updated, and Removed status values
-		// since Additions are handled separately.
-		// Added's iota value is `3` so we can iterate over [0..2] contiguously.
-		for status := range 3 {
-			result.C[status].Add(counts[worker][status])
+	if rc.opts.IdentityFirst {
+		if j, ok := rc.identity(i); ok {
+			return j, rc.status(i, j)
 		}
+		if j, ok := rc.exact(i); ok {
+			return j, rc.status(i, j)
+		}
+		return null, Removed
 	}
 
-	for _, entries := range additions {
-		result.E = append(result.E, entries...)
-		result.C[Added].Add(uint32(len(entries))) // #nosec G115
+	if j, ok := rc.exact(i); ok {
+		return j, rc.status(i, j)
 	}
+	if j, ok := rc.identity(i); ok {
+		return j, rc.status(i, j)
+	}
+	return null, Removed
+}
 
-	return result
+// exact looks up a new file with the same name as old file i.
+// The new file at the same position is preferred, so duplicate names pair up by position.
+func (rc *reconciler) exact(i int) (uint32, bool) {
+	if k := rc.offset + i; k < len(rc.cur) && rc.old[i] == rc.cur[k] {
+		return uint32(k), true // #nosec G115
+	}
+
+	return rc.exactLookup(i)
+}
+
+// exactLookup looks up the last new file with the same name as old file i in the table.
+func (rc *reconciler) exactLookup(i int) (uint32, bool) {
+	if rc.filter != nil && !rc.filter.has(rc.oldEntries[i]) {
+		return null, false
+	}
+
+	j, ok := rc.table.get(rc.oldEntries[i] | identity.ExactFlag)
+	if !ok {
+		return null, false
+	}
+	if rc.old[i] != rc.cur[j] {
+		if rc.stats != nil {
+			rc.stats.exactCollisions.Add(1)
+		}
+		return null, false
+	}
+
+	return j, true
+}
+
+// identity looks up the first new file sharing the identity of old file i.
+func (rc *reconciler) identity(i int) (uint32, bool) {
+	j, ok := rc.table.get(rc.oldHashes[i])
+	if !ok || !rc.equal(i, j) {
+		return null, false
+	}
+
+	return j, true
+}
+
+// equal reports whether old file i and new file j share an identity,
+// counting the comparison when Options.Stats is set.
+func (rc *reconciler) equal(i int, j uint32) bool {
+	eq := rc.cfg.Equal(rc.old[i], rc.cur[j])
+	if rc.stats != nil {
+		rc.stats.identityComparisons.Add(1)
+		if !eq {
+			rc.stats.identityCollisions.Add(1)
+		}
+	}
+
+	return eq
+}
+
+// status returns the status of old file i matched to new file j.
+// Identical names are reported as Unchanged (or ContentChanged) rather than Updated.
+func (rc *reconciler) status(i int, j uint32) Status {
+	if rc.old[i] == rc.cur[j] {
+		return rc.same(i, j)
+	}
+
+	return Updated
+}
+
+// renames converts Removed entries into Renamed entries when an unmatched new file has the
+// same content hash and extension (see Options.DetectRenames) and returns the number converted.
+// This runs after all workers have finished, so the entries are visited in old file order.
+func (rc *reconciler) renames(results [][]Entry) uint32 {
+	unmatched := make(map[uint64][]uint32)
+	for j := range rc.cur {
+		fileIdx := uint32(j) // #nosec G115
+		if !identity.IsMarked(rc.matches, fileIdx) {
+			h := rc.opts.CurContent[j]
+			unmatched[h] = append(unmatched[h], fileIdx)
+		}
+	}
+	if len(unmatched) == 0 {
+		return 0
+	}
+
+	var renamed uint32
+	for _, entries := range results {
+		for k, e := range entries {
+			if e.Status() != Removed {
+				continue
+			}
+
+			i := e.Old()
+			h := rc.opts.OldContent[i]
+			candidates := unmatched[h]
+			for n, j := range candidates {
+				if path.Ext(rc.old[i]) == path.Ext(rc.cur[j]) {
+					identity.TryMark(rc.matches, j)
+					entries[k] = newEntry(i, j, Renamed)
+					unmatched[h] = slices.Delete(candidates, n, n+1)
+					renamed++
+					break
+				}
+			}
+		}
+	}
+
+	return renamed
+}
+
+// same classifies a pair of files with identical names using their content hashes if available.
+func (rc *reconciler) same(i int, j uint32) Status {
+	if rc.content && rc.opts.OldContent[i] != rc.opts.CurContent[j] {
+		return ContentChanged
+	}
+
+	return Unchanged
 }
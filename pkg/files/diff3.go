@@ -0,0 +1,179 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"runtime"
+)
+
+// Status3 is the classification of a file in a three-way diff.
+type Status3 uint8
+
+const (
+	UnchangedBoth Status3 = iota // Unchanged in ours and theirs
+	UpdatedOurs                  // Updated in ours and unchanged in theirs
+	UpdatedTheirs                // Updated in theirs and unchanged in ours
+	UpdatedBoth                  // Updated to the same name in ours and theirs
+	RemovedOurs                  // Removed in ours and unchanged in theirs
+	RemovedTheirs                // Removed in theirs and unchanged in ours
+	RemovedBoth                  // Removed in ours and theirs
+	AddedOurs                    // Added in ours only
+	AddedTheirs                  // Added in theirs only
+	AddedBoth                    // Added with the same name in ours and theirs
+	Conflict                     // Changed differently in ours and theirs (or updated in one and removed in the other)
+
+	numStatuses3 = int(Conflict) + 1
+)
+
+// status3Strings holds the name of each status returned by Status3.String.
+var status3Strings = [numStatuses3]string{
+	"UnchangedBoth", "UpdatedOurs", "UpdatedTheirs", "UpdatedBoth",
+	"RemovedOurs", "RemovedTheirs", "RemovedBoth",
+	"AddedOurs", "AddedTheirs", "AddedBoth", "Conflict",
+}
+
+// String returns the name of the status (e.g., "UpdatedOurs"), or "Status3(N)" for unknown values.
+func (s Status3) String() string {
+	if int(s) < numStatuses3 {
+		return status3Strings[s]
+	}
+
+	return fmt.Sprintf("Status3(%d)", uint8(s))
+}
+
+// Entry3 represents a single three-way reconciliation result.
+// Indices refer to the base, ours, and theirs file lists and are null (0xFFFFFFFF)
+// when the file does not exist on that side.
+type Entry3 struct {
+	Base   uint32
+	Ours   uint32
+	Theirs uint32
+	Status Status3
+}
+
+// Result3 contains the output of Diff3.
+type Result3 struct {
+	E []Entry3             // Entries for every base file followed by the additions of ours and then theirs
+	C [numStatuses3]uint32 // Counts of the above statuses indexed by their respective integer values
+}
+
+// Count returns the number of entries with the given status.
+func (r *Result3) Count(s Status3) uint32 { return r.C[s] }
+
+// All returns an iterator over all entries with their status.
+func (r *Result3) All() iter.Seq2[Status3, Entry3] {
+	return func(yield func(Status3, Entry3) bool) {
+		for _, e := range r.E {
+			if !yield(e.Status, e) {
+				return
+			}
+		}
+	}
+}
+
+// Diff3 reconciles a common base file list against two derived file lists, ours and theirs,
+// by diffing each of them against base.
+//
+// Each base file is classified by what happened to it on each side. A base file that was
+// updated on both sides is UpdatedBoth when both sides use the same new name and a Conflict
+// otherwise, as is a base file that was updated on one side and removed on the other.
+// Files added on both sides with the same name are AddedBoth.
+// Diff3 panics if ours or theirs holds more than 2^29-1 files (see Entry); Diff3Context
+// returns ErrTooManyEntries instead.
+func Diff3(base, ours, theirs []string) *Result3 {
+	r, err := Diff3Context(context.Background(), base, ours, theirs)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// Diff3Context is like Diff3 but stops early and returns ctx.Err() when ctx is cancelled,
+// and returns ErrTooManyEntries rather than panicking when ours or theirs holds more than
+// 2^29-1 files.
+func Diff3Context(ctx context.Context, base, ours, theirs []string) (*Result3, error) {
+	workers := max(1, runtime.GOMAXPROCS(0))
+	a, err := diffContext(ctx, base, ours, workers, &Options{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := diffContext(ctx, base, theirs, workers, &Options{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries for base files come first, in base order, on both sides.
+	r := &Result3{E: make([]Entry3, 0, len(base)+int(a.Count(Added))+int(b.Count(Added)))}
+	for i := range base {
+		ea, eb := a.E[i], b.E[i]
+		r.add(Entry3{
+			Base:   uint32(i), // #nosec G115
+			Ours:   ea.New(),
+			Theirs: eb.New(),
+			Status: classify3(ea, eb, ours, theirs),
+		})
+	}
+
+	// Pair additions with the same name in the order they appear on each side.
+	addedTheirs := make(map[string][]uint32, b.Count(Added))
+	for _, e := range b.E[len(base):] {
+		name := theirs[e.New()]
+		addedTheirs[name] = append(addedTheirs[name], e.New())
+	}
+
+	paired := make(map[uint32]bool)
+	for _, e := range a.E[len(base):] {
+		name := ours[e.New()]
+		js := addedTheirs[name]
+		if len(js) == 0 {
+			r.add(Entry3{Base: null, Ours: e.New(), Theirs: null, Status: AddedOurs})
+			continue
+		}
+
+		r.add(Entry3{Base: null, Ours: e.New(), Theirs: js[0], Status: AddedBoth})
+		addedTheirs[name] = js[1:]
+		paired[js[0]] = true
+	}
+
+	for _, e := range b.E[len(base):] {
+		if !paired[e.New()] {
+			r.add(Entry3{Base: null, Ours: null, Theirs: e.New(), Status: AddedTheirs})
+		}
+	}
+
+	return r, nil
+}
+
+// add appends an entry and counts its status.
+func (r *Result3) add(e Entry3) {
+	r.E = append(r.E, e)
+	r.C[e.Status]++
+}
+
+// classify3 classifies a base file from its entries in the ours and theirs diffs.
+func classify3(a, b Entry, ours, theirs []string) Status3 {
+	sa, sb := a.Status(), b.Status()
+
+	switch {
+	case sa == Unchanged && sb == Unchanged:
+		return UnchangedBoth
+	case sa == Removed && sb == Removed:
+		return RemovedBoth
+	case sa == Removed && sb == Unchanged:
+		return RemovedOurs
+	case sa == Unchanged && sb == Removed:
+		return RemovedTheirs
+	case sa == Removed || sb == Removed:
+		return Conflict
+	case sb == Unchanged:
+		return UpdatedOurs
+	case sa == Unchanged:
+		return UpdatedTheirs
+	case ours[a.New()] == theirs[b.New()]:
+		return UpdatedBoth
+	default:
+		return Conflict
+	}
+}
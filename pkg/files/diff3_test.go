@@ -0,0 +1,114 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDiff3_Clean(t *testing.T) {
+	base := []string{"etc/app.conf", "lib/libfoo.so.1", "lib/libbar.so.1", "bin/old", "bin/gone"}
+	ours := []string{"etc/app.conf", "lib/libfoo.so.2", "lib/libbar.so.1", "bin/gone", "share/ours.txt", "share/both.txt"}
+	theirs := []string{"etc/app.conf", "lib/libfoo.so.1", "lib/libbar.so.2", "share/both.txt", "share/theirs.txt"}
+
+	r := Diff3(base, ours, theirs)
+
+	want := []Entry3{
+		{0, 0, 0, UnchangedBoth},
+		{1, 1, 1, UpdatedOurs},
+		{2, 2, 2, UpdatedTheirs},
+		{3, null, null, RemovedBoth},
+		{4, 3, null, RemovedTheirs},
+		{null, 4, null, AddedOurs},
+		{null, 5, 3, AddedBoth},
+		{null, null, 4, AddedTheirs},
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %v, want %v", r.E, want)
+	}
+
+	for _, s := range []Status3{UnchangedBoth, UpdatedOurs, UpdatedTheirs, RemovedBoth, RemovedTheirs, AddedOurs, AddedBoth, AddedTheirs} {
+		if r.Count(s) != 1 {
+			t.Errorf("Count(%v) = %d, want 1", s, r.Count(s))
+		}
+	}
+	if r.Count(Conflict) != 0 {
+		t.Errorf("Count(Conflict) = %d, want 0", r.Count(Conflict))
+	}
+
+	var n int
+	for s, e := range r.All() {
+		if s != e.Status {
+			t.Errorf("All() yielded status %v for entry %v", s, e)
+		}
+		n++
+	}
+	if n != len(r.E) {
+		t.Errorf("All() yielded %d entries, want %d", n, len(r.E))
+	}
+}
+
+func TestDiff3_Conflict(t *testing.T) {
+	base := []string{"lib/libfoo.so.1", "lib/libbar.so.1", "lib/libbaz.so.1"}
+	ours := []string{"lib/libfoo.so.2", "lib/libbar.so.2", "lib/libbaz.so.2"}
+	theirs := []string{"lib/libfoo.so.3", "lib/libbar.so.2"}
+
+	r := Diff3(base, ours, theirs)
+
+	want := []Entry3{
+		{0, 0, 0, Conflict},    // updated differently on each side
+		{1, 1, 1, UpdatedBoth}, // updated the same way on each side
+		{2, 2, null, Conflict}, // updated in ours and removed in theirs
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %v, want %v", r.E, want)
+	}
+	if r.Count(Conflict) != 2 || r.Count(UpdatedBoth) != 1 {
+		t.Errorf("conflicts=%d updatedBoth=%d, want 2 and 1", r.Count(Conflict), r.Count(UpdatedBoth))
+	}
+}
+
+func TestDiff3_DuplicateAdditions(t *testing.T) {
+	r := Diff3(nil, []string{"a.txt"}, []string{"a.txt", "a.txt"})
+
+	want := []Entry3{{null, 0, 0, AddedBoth}, {null, null, 1, AddedTheirs}}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %v, want %v", r.E, want)
+	}
+
+	if got := Diff3(nil, nil, nil); len(got.E) != 0 {
+		t.Errorf("empty Diff3 entries = %v, want none", got.E)
+	}
+}
+
+func TestStatus3_String(t *testing.T) {
+	if got := UpdatedOurs.String(); got != "UpdatedOurs" {
+		t.Errorf("UpdatedOurs.String() = %q", got)
+	}
+	if got := Conflict.String(); got != "Conflict" {
+		t.Errorf("Conflict.String() = %q", got)
+	}
+	if got := Status3(42).String(); got != "Status3(42)" {
+		t.Errorf("Status3(42).String() = %q", got)
+	}
+}
+
+func TestDiff3Context(t *testing.T) {
+	base, ours := genMixed(5_000)
+	_, theirs := genData(5_000)
+
+	r, err := Diff3Context(context.Background(), base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Diff3Context() error = %v", err)
+	}
+	if want := Diff3(base, ours, theirs); !slices.Equal(r.E, want.E) || r.C != want.C {
+		t.Error("Diff3Context() entries differ from Diff3")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r, err := Diff3Context(ctx, base, ours, theirs); !errors.Is(err, context.Canceled) || r != nil {
+		t.Errorf("cancelled Diff3Context() = %v, %v, want context.Canceled", r, err)
+	}
+}
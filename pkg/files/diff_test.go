@@ -1,11 +1,17 @@
 package files
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"hash/maphash"
 	"runtime"
 	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/synctest"
+	"unsafe"
 
 	"github.com/egibs/reconcile/internal/identity"
 	"go.uber.org/goleak"
@@ -78,6 +84,32 @@ func TestHash_DifferentIdentity(t *testing.T) {
 	}
 }
 
+func TestHashAllSpans(t *testing.T) {
+	files := []string{
+		"libfoo.so.1.2.3",
+		"alpine-baselayout-3.6.8-r1.Q17OteNVXn9.post-install",
+		"foo.1.2.3.so",
+		"app-1.0.0-r5",
+		"README.md",
+		"",
+	}
+
+	for _, workers := range []int{1, 2, 4} {
+		idHashes, exHashes, spans := identity.HashAllSpans(files, workers, seed)
+		wantID, wantEx := identity.HashAll(files, workers, seed)
+
+		for i, f := range files {
+			j, s, e := identity.Spans([]byte(f))
+			if spans[i] != (identity.Span{J: j, S: s, E: e}) {
+				t.Errorf("HashAllSpans(%q) span = %+v, want {%d %d %d}", f, spans[i], j, s, e)
+			}
+			if idHashes[i] != wantID[i] || exHashes[i] != wantEx[i] {
+				t.Errorf("HashAllSpans(%q) hashes differ from HashAll with workers=%d", f, workers)
+			}
+		}
+	}
+}
+
 func TestEqual(t *testing.T) {
 	cases := []struct {
 		a, b string
@@ -109,6 +141,163 @@ func TestDiff_Determinism(t *testing.T) {
 			t.Fatal("non-deterministic")
 		}
 	}
+
+	// Many old files compete for few new files with the same identity (and name), so
+	// which old file gets each new file must not depend on the workers.
+	old, cur = nil, nil
+	for i := range 5_000 {
+		old = append(old, fmt.Sprintf("lib/libdup%d.so.%d", i%50, i%7))
+		if i%3 == 0 {
+			cur = append(cur, fmt.Sprintf("lib/libdup%d.so.%d", i%50, i%5))
+		}
+	}
+
+	want, err := DiffReaders(strings.NewReader(strings.Join(old, "\n")), strings.NewReader(strings.Join(cur, "\n")), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 2, 3, 4, 8, 16, 64} {
+		for _, ws := range []bool{false, true} {
+			for _, identityFirst := range []bool{false, true} {
+				opts := Options{Workers: workers, WorkStealing: ws, IdentityFirst: identityFirst}
+				r := mustDiff(t, old, cur, opts)
+
+				base := want
+				if identityFirst {
+					base = mustDiff(t, old, cur, Options{Workers: 1, IdentityFirst: true})
+				}
+				if !slices.Equal(r.E, base.E) || r.Summary() != base.Summary() {
+					t.Errorf("workers=%d stealing=%v identityFirst=%v: result differs from sequential reconciliation", workers, ws, identityFirst)
+				}
+			}
+		}
+	}
+}
+
+func TestDiff_ClaimDisplaced(t *testing.T) {
+	// Old file 1 loses "lib.so.1" to old file 0 and then takes "lib.so.2" by identity before
+	// old file 2, which prefers it by name, so old file 2 is matched again and removed.
+	old := []string{"lib.so.1", "lib.so.1", "lib.so.2"}
+	cur := []string{"lib.so.2", "lib.so.1"}
+	want := []Entry{newEntry(0, 1, Unchanged), newEntry(1, 0, Updated), newEntry(2, null, Removed)}
+
+	for _, workers := range []int{1, 2, 3} {
+		r := mustDiff(t, old, cur, Options{Workers: workers})
+		if !slices.Equal(r.E, want) || r.Count(Updated) != 1 || r.Count(Removed) != 1 {
+			t.Errorf("workers=%d: entries = %v (%s), want %v", workers, r.E, r.Summary(), want)
+		}
+	}
+}
+
+// mergeSources splits n entries into the per-range results and additions of the given
+// number of workers as merge receives them, with an extra empty range after the first.
+func mergeSources(n, workers int) ([][]Entry, [][]Entry, [][numStatuses]uint32) {
+	results := make([][]Entry, workers+1)
+	additions := make([][]Entry, workers+1)
+	counts := make([][numStatuses]uint32, workers+1)
+
+	for i := range n {
+		w := i * workers / n
+		if w > 0 {
+			w++ // Leave range 1 empty
+		}
+
+		idx := uint32(i) // #nosec G115
+		if i%3 == 0 {
+			additions[w] = append(additions[w], newEntry(null, idx, Added))
+		} else {
+			results[w] = append(results[w], newEntry(idx, idx, Unchanged))
+			counts[w][Unchanged]++
+		}
+	}
+
+	return results, additions, counts
+}
+
+func TestMerge(t *testing.T) {
+	for _, n := range []int{0, 10, mergeParallelMin - 1, 3*mergeParallelMin + 7} {
+		results, additions, counts := mergeSources(n, 4)
+		want := slices.Concat(slices.Concat(results...), slices.Concat(additions...))
+
+		for _, workers := range []int{1, 3, 4, 16} {
+			r := merge(results, additions, counts, workers)
+			if !slices.Equal(r.E, want) {
+				t.Errorf("n=%d workers=%d: entries differ from serial concatenation", n, workers)
+			}
+			if got := int(r.Count(Unchanged) + r.Count(Added)); got != len(want) {
+				t.Errorf("n=%d workers=%d: counted %d entries, want %d", n, workers, got, len(want))
+			}
+		}
+	}
+}
+
+func TestDiffN(t *testing.T) {
+	old, cur := genMixed(5_000)
+	want := Diff(old, cur)
+
+	for _, workers := range []int{-1, 0, 1, 2, 3, 8, 64} {
+		got := DiffN(old, cur, workers)
+		if !slices.Equal(got.E, want.E) {
+			t.Errorf("workers=%d: entries differ from Diff", workers)
+		}
+		for s := range numStatuses {
+			if got.C[s].Load() != want.C[s].Load() {
+				t.Errorf("workers=%d: count %d = %d, want %d", workers, s, got.C[s].Load(), want.C[s].Load())
+			}
+		}
+	}
+}
+
+func TestDiffContext(t *testing.T) {
+	old, cur := genMixed(5_000)
+
+	r, err := DiffContext(context.Background(), old, cur)
+	if err != nil {
+		t.Fatalf("DiffContext() error = %v", err)
+	}
+	if want := Diff(old, cur); !slices.Equal(r.E, want.E) {
+		t.Error("DiffContext() entries differ from Diff")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r, err := DiffContext(ctx, old, cur); !errors.Is(err, context.Canceled) || r != nil {
+		t.Errorf("cancelled DiffContext() = %v, %v, want context.Canceled", r, err)
+	}
+}
+
+func TestDiffContext_Cancel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large input")
+	}
+
+	old, cur := genData(1_000_000)
+
+	// Cancel once the first batch of old files has been reconciled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls, completed atomic.Int32
+	opts := &Options{
+		Progress: func(done, total int) {
+			calls.Add(1)
+			if done == total {
+				completed.Add(1)
+			}
+			cancel()
+		},
+	}
+
+	r, err := diffContext(ctx, old, cur, 4, opts, nil)
+	if !errors.Is(err, context.Canceled) || err != ctx.Err() || r != nil {
+		t.Fatalf("diffContext() = %v, %v, want %v", r, err, ctx.Err())
+	}
+
+	// Reconciliation stops short of the total once cancelled.
+	if calls.Load() == 0 || completed.Load() != 0 {
+		t.Errorf("Progress calls = %d, reaching the total = %d, want some and none", calls.Load(), completed.Load())
+	}
 }
 
 func TestDiff_Empty(t *testing.T) {
@@ -119,6 +308,42 @@ func TestDiff_Empty(t *testing.T) {
 	}
 }
 
+func TestDiff_OneSided(t *testing.T) {
+	files := []string{"a.so.1", "b-1.0", "c.txt"}
+
+	r := Diff(nil, files)
+	if r.Count(Added) != 3 || len(r.E) != 3 {
+		t.Errorf("added = %d, entries = %d, want 3 and 3", r.Count(Added), len(r.E))
+	}
+	for i, e := range r.E {
+		if e != (newEntry(null, uint32(i), Added)) {
+			t.Errorf("entry %d = %+v, want Added at index %d", i, e, i)
+		}
+	}
+
+	r = Diff(files, nil)
+	if r.Count(Removed) != 3 || len(r.E) != 3 {
+		t.Errorf("removed = %d, entries = %d, want 3 and 3", r.Count(Removed), len(r.E))
+	}
+	for i, e := range r.E {
+		if e != (newEntry(uint32(i), null, Removed)) {
+			t.Errorf("entry %d = %+v, want Removed at index %d", i, e, i)
+		}
+	}
+}
+
+func TestGenMixed(t *testing.T) {
+	old, cur := genMixed(800)
+
+	r := Diff(old, cur)
+
+	want := [4]uint32{300, 400, 100, 100} // Unchanged, Updated, Removed, Added
+	got := [4]uint32{r.Count(Unchanged), r.Count(Updated), r.Count(Removed), r.Count(Added)}
+	if got != want {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
+
 func TestDiff_LargeScale(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping large test")
@@ -158,6 +383,8 @@ func BenchmarkHash(b *testing.B) {
 		"app-1.0.0-r5",
 		"foo.1.2.3.so",
 		"README.md",
+		"libcublas.so.12.3.4.1",
+		"libamdhip64.so.6",
 	}
 
 	for b.Loop() {
@@ -167,6 +394,65 @@ func BenchmarkHash(b *testing.B) {
 	}
 }
 
+// TestHash_LongPaths checks that identity and exact hashes of long paths, which hash the
+// identity prefix and the whole path in a single pass, match hashing each separately.
+func TestHash_LongPaths(t *testing.T) {
+	for _, n := range []int{0, 100, 250, 256, 500, 1000} {
+		dir := strings.Repeat("d/", n/2)
+		for _, p := range []string{dir + "libfoo.so.1.2.3", dir + "foo.1.2.3.so", dir + "README.md"} {
+			bs := []byte(p)
+			j, s, e := identity.Spans(bs)
+
+			want := maphash.Bytes(seed, bs[:j])
+			if s != e {
+				want ^= maphash.Bytes(seed, bs[s:e])
+			}
+			wantExact := maphash.Bytes(seed, bs) &^ identity.ExactFlag
+
+			id, exact := identity.Hash(p, seed)
+			if id != want&^identity.ExactFlag || exact != wantExact {
+				t.Errorf("Hash(%d byte path) = (%x, %x), want (%x, %x)", len(p), id, exact, want&^identity.ExactFlag, wantExact)
+			}
+		}
+	}
+}
+
+// BenchmarkHash_Long compares Hash against hashing the identity prefix and the
+// whole path separately for paths of increasing length.
+func BenchmarkHash_Long(b *testing.B) {
+	for _, n := range []int{64, 256, 512, 1024} {
+		p := strings.Repeat("d/", (n-15)/2) + "libfoo.so.1.2.3"
+		bs := []byte(p)
+		j, _, _ := identity.Spans(bs)
+
+		b.Run(fmt.Sprintf("len=%d/Hash", len(p)), func(b *testing.B) {
+			for b.Loop() {
+				identity.Hash(p, seed)
+			}
+		})
+		b.Run(fmt.Sprintf("len=%d/separate", len(p)), func(b *testing.B) {
+			for b.Loop() {
+				identity.Spans(bs)
+				_ = maphash.Bytes(seed, bs[:j]) ^ maphash.Bytes(seed, bs)
+			}
+		})
+	}
+}
+
+func BenchmarkHash_ConcatSpans(b *testing.B) {
+	cfg := &identity.Config{ConcatSpans: true}
+	paths := []string{
+		"foo.1.2.3.so",
+		"busybox-1.37.0-r12.Q1sSNCl4MTQ0d1V/0NTXAhIjY7Nqo=.trigger",
+	}
+
+	for b.Loop() {
+		for _, p := range paths {
+			cfg.Hash(p, seed)
+		}
+	}
+}
+
 func BenchmarkDiff1K(b *testing.B)   { benchDiff(b, 1_000) }
 func BenchmarkDiff10K(b *testing.B)  { benchDiff(b, 10_000) }
 func BenchmarkDiff100K(b *testing.B) { benchDiff(b, 100_000) }
@@ -183,6 +469,24 @@ func benchDiff(b *testing.B, n int) {
 	}
 }
 
+func BenchmarkDiff1M_AddedOnly(b *testing.B) {
+	_, cur := genData(1_000_000)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for range b.N {
+		Diff(nil, cur)
+	}
+}
+
+func BenchmarkDiff1M_RemovedOnly(b *testing.B) {
+	old, _ := genData(1_000_000)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for range b.N {
+		Diff(old, nil)
+	}
+}
+
 func BenchmarkDiff1M_Workers(b *testing.B) {
 	old, cur := genData(1_000_000)
 	for _, w := range []int{1, 2, 4, 8, 16} {
@@ -195,6 +499,17 @@ func BenchmarkDiff1M_Workers(b *testing.B) {
 	}
 }
 
+// BenchmarkMerge10M isolates the cost of merging 10M entries into a Result.
+func BenchmarkMerge10M(b *testing.B) {
+	workers := max(1, runtime.GOMAXPROCS(0))
+	results, additions, counts := mergeSources(10_000_000, max(2, workers))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		merge(results, additions, counts, workers)
+	}
+}
+
 func BenchmarkMemory1M(b *testing.B) {
 	old, cur := genData(1_000_000)
 
@@ -209,6 +524,7 @@ func BenchmarkMemory1M(b *testing.B) {
 
 	b.ReportMetric(float64(m2.TotalAlloc-m1.TotalAlloc)/1e6, "MB-alloc")
 	b.ReportMetric(float64(m2.HeapAlloc-m1.HeapAlloc)/1e6, "MB-heap")
+	b.ReportMetric(float64(cap(r.E))*float64(unsafe.Sizeof(Entry{}))/1e6, "MB-entries")
 }
 
 func genData(n int) ([]string, []string) {
@@ -221,6 +537,57 @@ func genData(n int) ([]string, []string) {
 	return old, cur
 }
 
+func BenchmarkDiffMixed1K(b *testing.B)   { benchDiffMixed(b, 1_000) }
+func BenchmarkDiffMixed100K(b *testing.B) { benchDiffMixed(b, 100_000) }
+func BenchmarkDiffMixed1M(b *testing.B)   { benchDiffMixed(b, 1_000_000) }
+
+func benchDiffMixed(b *testing.B, n int) {
+	b.Helper()
+	old, cur := genMixed(n)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for range b.N {
+		Diff(old, cur)
+	}
+}
+
+// genMixed generates a realistic blend of file names exercising each matcher.
+// Roughly half of the files are version bumps, a third are unchanged, and the
+// remainder are removed from old and added to cur.
+func genMixed(n int) ([]string, []string) {
+	old := make([]string, n)
+	cur := make([]string, n)
+
+	for i := range n {
+		v1, v2 := i%7, i%7+1
+		switch i % 8 {
+		case 0:
+			old[i] = fmt.Sprintf("usr/lib/libpkg%d.so.%d.0.0", i, v1)
+			cur[i] = fmt.Sprintf("usr/lib/libpkg%d.so.%d.0.0", i, v2)
+		case 1:
+			old[i] = fmt.Sprintf("var/cache/apk/pkg%d-%d.2.3-r%d", i, v1, v1)
+			cur[i] = fmt.Sprintf("var/cache/apk/pkg%d-%d.2.3-r%d", i, v2, v1)
+		case 2:
+			old[i] = fmt.Sprintf("lib/apk/db/pkg%d-%d.0.0-r0.Q1%08x=.post-install", i, v1, i)
+			cur[i] = fmt.Sprintf("lib/apk/db/pkg%d-%d.0.0-r0.Q1%08x=.post-install", i, v2, i*31)
+		case 3:
+			old[i] = fmt.Sprintf("opt/lib/p%d/plugin.%d.2.3.so", i, v1)
+			cur[i] = fmt.Sprintf("opt/lib/p%d/plugin.%d.2.3.so", i, v2)
+		case 4:
+			old[i] = fmt.Sprintf("dist/tool%d_%d.0.0_linux_amd64.tar.gz", i, v1)
+			cur[i] = fmt.Sprintf("dist/tool%d_%d.0.0_linux_amd64.tar.gz", i, v1)
+		case 5, 6:
+			old[i] = fmt.Sprintf("usr/share/doc/pkg%d/README.md", i)
+			cur[i] = old[i]
+		default:
+			old[i] = fmt.Sprintf("etc/old/config%d.conf", i)
+			cur[i] = fmt.Sprintf("etc/new/config%d.conf", i)
+		}
+	}
+
+	return old, cur
+}
+
 // Benchmarks for individual pattern matchers
 
 func BenchmarkSoname(b *testing.B) {
@@ -265,6 +632,62 @@ func BenchmarkSuffix(b *testing.B) {
 	}
 }
 
+func BenchmarkDeb(b *testing.B) {
+	paths := [][]byte{
+		[]byte("nginx_1.24.0-1_amd64.deb"),
+		[]byte("libssl3_3.0.11-1~deb12u2_amd64.deb"),
+		[]byte("libc6_1:2.36-9_arm64.deb"),
+		[]byte("usr/bin/ls"), // no match
+	}
+	for b.Loop() {
+		for _, p := range paths {
+			identity.Deb(p)
+		}
+	}
+}
+
+func BenchmarkJar(b *testing.B) {
+	paths := [][]byte{
+		[]byte("guava-33.0.0-jre.jar"),
+		[]byte("commons-lang3-3.14.0.jar"),
+		[]byte("foo-1.2.3-sources.jar"),
+		[]byte("usr/bin/ls"), // no match
+	}
+	for b.Loop() {
+		for _, p := range paths {
+			identity.Jar(p)
+		}
+	}
+}
+
+func BenchmarkGem(b *testing.B) {
+	paths := [][]byte{
+		[]byte("rails-7.1.3.gem"),
+		[]byte("nokogiri-1.16.0-x86_64-linux.gem"),
+		[]byte("cache/aws-sdk-s3-1.142.0.gem"),
+		[]byte("usr/bin/ls"), // no match
+	}
+	for b.Loop() {
+		for _, p := range paths {
+			identity.Gem(p)
+		}
+	}
+}
+
+func BenchmarkContentHash(b *testing.B) {
+	paths := [][]byte{
+		[]byte("main.4f3a9c1b.js"),
+		[]byte("app.9f8e7d6c.chunk.js"),
+		[]byte("jquery.min.js"),
+		[]byte("usr/bin/ls"), // no match
+	}
+	for b.Loop() {
+		for _, p := range paths {
+			identity.ContentHash(p)
+		}
+	}
+}
+
 func BenchmarkEmbedded(b *testing.B) {
 	paths := [][]byte{
 		[]byte("foo.1.2.3.so"),
@@ -274,7 +697,7 @@ func BenchmarkEmbedded(b *testing.B) {
 	}
 	for b.Loop() {
 		for _, p := range paths {
-			identity.Embedded(p)
+			identity.Embedded(p, identity.DefaultEmbeddedExtensions)
 		}
 	}
 }
@@ -308,6 +731,20 @@ func BenchmarkEqual(b *testing.B) {
 	}
 }
 
+func BenchmarkEqual_Mismatched(b *testing.B) {
+	pairs := [][2]string{
+		{"lib/libcrypto.so.1.1.0", "usr/share/doc/openssl/README.md"},
+		{"alpine-baselayout-3.6.8-r1.Q17OteNVXn9.post-install", "busybox-1.37.0-r12.Q1sSNCl4MTQ0.trigger"},
+		{"app-1.0.0-r5", "zlib-1.3.1-r0"},
+		{"usr/bin/ls", "bin/busybox"},
+	}
+	for b.Loop() {
+		for _, p := range pairs {
+			identity.Equal(p[0], p[1])
+		}
+	}
+}
+
 // Additional pattern matcher tests.
 func TestSoname(t *testing.T) {
 	tests := []struct {
@@ -322,6 +759,12 @@ func TestSoname(t *testing.T) {
 		{"libfoo.so", 0},                   // no version
 		{"foo.txt", 0},                     // not a .so
 		{".so.1", 3},                       // minimal match (edge case)
+		// GPU libraries
+		{"libcudart.so.12", 12},                            // identity: libcudart.so
+		{"libcudart.so.13", 12},                            // identity: libcudart.so
+		{"libcublas.so.12.3.4.1", 12},                      // identity: libcublas.so
+		{"libamdhip64.so.6", 14},                           // identity: libamdhip64.so
+		{"usr/local/cuda/lib64/libcudart.so.12.3.101", 33}, // identity: usr/local/cuda/lib64/libcudart.so
 	}
 
 	for _, tt := range tests {
@@ -332,6 +775,19 @@ func TestSoname(t *testing.T) {
 	}
 }
 
+func TestDiff_GPULibraries(t *testing.T) {
+	old := []string{"libcudart.so.12", "libcublas.so.12.3.4.1", "libamdhip64.so.6", "libcublasLt.so.12"}
+	cur := []string{"libcudart.so.13", "libcublas.so.13.0.0.19", "libamdhip64.so.7", "libcublasLt.so.13"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
 func TestScript(t *testing.T) {
 	tests := []struct {
 		input      string
@@ -347,6 +803,15 @@ func TestScript(t *testing.T) {
 		{"usr/bin/ls", 0, 0},        // no match
 		{"foo.post-install", 0, 0},  // no .Q1
 		{"foo-1.0.Q1xxx.txt", 0, 0}, // wrong suffix
+		// Full checksums with '/', '+', and '=' padding.
+		{"alpine-baselayout-3.6.8-r1.Q17OteNVXn9/iSXcJI1Vf8x0TVc9Y=.post-install", 17, 57},
+		{"busybox-1.37.0-r12.Q1sSNCl4MTQ0d1V/0NTXAhIjY7Nqo=.trigger", 7, 49},
+		{"lib/apk/scripts/busybox-1.37.0-r12.Q1sSNCl4MTQ0d1V/0NTXAhIjY7Nqo=.trigger", 23, 65},
+		{"foo-1.0-r0.Q1a+b/c+d==.pre-upgrade", 3, 22},
+		{"foo-1.0-r0.Q1a_b-c-1.trigger", 3, 20},          // base64url
+		{"foo-1.0-r0.Q1not base64.trigger", 0, 0},        // invalid checksum byte
+		{"app-2/foo.Q1abc/def=.post-install", 0, 0},      // version only in a directory
+		{"app-2/foo-1.0.Q1abc/def=.post-install", 9, 24}, // version in the checksum's segment
 	}
 
 	for _, tt := range tests {
@@ -358,6 +823,23 @@ func TestScript(t *testing.T) {
 	}
 }
 
+func TestDiff_ScriptChecksumSlash(t *testing.T) {
+	old := []string{
+		"foo-1.0-r0.Q1abc/def=.post-install",
+		"lib/apk/alpine-baselayout-3.6.8-r1.Q17OteNVXn9/iSXcJI1Vf8x0TVc9Y=.post-install",
+	}
+	cur := []string{
+		"foo-1.1-r0.Q1xyz/uvw=.post-install",
+		"lib/apk/alpine-baselayout-3.7.0-r0.Q1sSNCl4MTQ0d1V/0NTXAhIjY7Nqo=.post-install",
+	}
+
+	r := Diff(old, cur)
+	want := []Entry{newEntry(0, 0, Updated), newEntry(1, 1, Updated)}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
 func TestSuffix(t *testing.T) {
 	tests := []struct {
 		input string
@@ -367,9 +849,24 @@ func TestSuffix(t *testing.T) {
 		{"app-1.0.0-r5", 3},
 		{"tool-2.3.4-beta1", 4},
 		{"python-3.11", 6},
-		{"usr/bin/ls", 0}, // no version suffix
-		{"foo", 0},        // too short
-		{"foo-bar", 0},    // no digit after -
+		{"foo-1.2.3-alpha.1+build.5", 3}, // SemVer pre-release and build metadata
+		{"foo-1.2.3-rc.2", 3},
+		{"foo-1.2.3+build.7", 3},
+		{"foo-bar-1.2.3-rc.2-r1", 7},
+		{"pkg-2:1.2.3-r0", 3}, // epoch
+		{"pkg-12:1.2.3", 3},
+		{"pkg2:1.2.3", 0},    // no "-" before the epoch
+		{"pkg-2:beta", 0},    // epoch without a version
+		{"pkg-:1.2.3", 0},    // empty epoch
+		{"usr/bin/ls", 0},    // no version suffix
+		{"foo", 0},           // too short
+		{"foo-bar", 0},       // no digit after -
+		{"build-1a2b3c4", 0}, // git short hash
+		{"build-0123456789abcdef0123456789abcdef01234567", 0}, // full git hash
+		{"app-1.2.3-1a2b3c4", 3},                              // hash after a version
+		{"build-1a2b3", 5},                                    // too short for a hash
+		{"build-20250114", 5},                                 // all digits
+		{"build-1A2B3C4", 5},                                  // upper case is not a git hash
 	}
 
 	for _, tt := range tests {
@@ -380,6 +877,99 @@ func TestSuffix(t *testing.T) {
 	}
 }
 
+func TestDiff_SuffixCommitHash(t *testing.T) {
+	old := []string{"dist/build-1a2b3c4", "dist/app-1.2.3"}
+	cur := []string{"dist/build-9f8e7d6", "dist/app-1.2.4"}
+
+	r := Diff(old, cur)
+	want := []Entry{
+		newEntry(0, null, Removed),
+		newEntry(1, 1, Updated),
+		newEntry(null, 0, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiff_DuplicateExactPositional(t *testing.T) {
+	r := Diff([]string{"x", "x"}, []string{"x", "x"})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Unchanged),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+
+	// Three or more duplicates all pair up when they stay in place.
+	old := []string{"x", "x", "x", "y"}
+	cur := []string{"x", "x", "x", "z"}
+	r = Diff(old, cur)
+	want = []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Unchanged),
+		newEntry(2, 2, Unchanged),
+		newEntry(3, null, Removed),
+		newEntry(null, 3, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiff_UnicodeDigits(t *testing.T) {
+	old := []string{"café-1.0.0", "文件-1.0", "app-１.０"}
+	cur := []string{"café-2.0.0", "文件-2.0", "app-２.０"}
+
+	r := Diff(old, cur)
+	want := []Entry{
+		newEntry(0, 0, Updated),
+		newEntry(1, 1, Updated),
+		newEntry(2, null, Removed),
+		newEntry(null, 2, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestSuffix_Toolchain(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"clang-18", "clang"},
+		{"clang++-18", "clang++"},
+		{"g++-13", "g++"},
+		{"llvm-ar-17", "llvm-ar"},
+		{"lld-16", "lld"},
+		{"usr/lib/llvm-18/bin/clang-18", "usr/lib/llvm-18/bin/clang"},
+		{"x86_64-linux-gnu-gcc-13", "x86_64-linux-gnu-gcc"},
+		{"clang-18.1.8", "clang"},
+	}
+
+	for _, tt := range tests {
+		j, s, e := identity.Spans([]byte(tt.input))
+		if got := tt.input[:j] + tt.input[s:e]; got != tt.want {
+			t.Errorf("identity of %q = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiff_Toolchain(t *testing.T) {
+	old := []string{"usr/bin/clang-18", "usr/bin/clang++-18", "usr/bin/llvm-ar-17", "usr/bin/lld-16"}
+	cur := []string{"usr/bin/clang-19", "usr/bin/clang++-19", "usr/bin/llvm-ar-18", "usr/bin/lld-17"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
 func TestEmbedded(t *testing.T) {
 	tests := []struct {
 		input string
@@ -389,18 +979,547 @@ func TestEmbedded(t *testing.T) {
 		{"foo.1.2.3.so", 3, 9},
 		{"bar.4.5.6.dylib", 3, 9},
 		{"libfoo.1.2.3.4.so", 6, 14},
-		{"foo.so", 0, 0},   // no embedded version
-		{"foo.1.so", 0, 0}, // only 1 dot in version
-		{"foo.txt", 0, 0},  // not a library
+		{"module.0.0.1.dll", 6, 12},
+		{"lib.10.20.30.a", 3, 12},
+		{"foo.so", 0, 0},             // no embedded version
+		{"foo.1.so", 0, 0},           // only 1 dot in version
+		{"foo.txt", 0, 0},            // not a library
+		{"rfc.1.2.3.txt", 0, 0},      // numbered document
+		{"chapter.1.2.3.html", 0, 0}, // numbered document
+		{"x.0.0.0.sox", 0, 0},        // extension only starts with a library extension
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.Embedded([]byte(tt.input), identity.DefaultEmbeddedExtensions)
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("Embedded(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestEmbedded_Bounds(t *testing.T) {
+	exts := []string{".b", ".x", ".y", ".so"}
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"a.1.2.3.b", 1, 7},        // shortest match
+		{"x.0.0.0.y", 1, 7},        // shortest match
+		{"short.1.2.3.x", 5, 11},   // ".x" is only a library extension when configured
+		{"ab.1.2.so", 2, 6},        // two dots
+		{".1.2.3.so", 0, 0},        // no name
+		{"a..1.2.so", 0, 0},        // no digit after the leading dot
+		{"abcdef..so", 0, 0},       // no version before the extension
+		{"abcdefg.1.2.", 0, 0},     // trailing dot
+		{"abcdefg.1.2.3", 0, 0},    // no extension
+		{"abcdefg1.2.3.so", 0, 0},  // no leading dot
+		{"a.1.2.3.so.so", 0, 0},    // version not directly before the extension
+		{"a.1.2.3.b/c.so", 0, 0},   // version in a directory
+		{"a.1.2.3.b.c.d.so", 0, 0}, // version not directly before the extension
 	}
 
 	for _, tt := range tests {
-		gotI, gotJ := identity.Embedded([]byte(tt.input))
+		gotI, gotJ := identity.Embedded([]byte(tt.input), exts)
 		if gotI != tt.wantI || gotJ != tt.wantJ {
 			t.Errorf("Embedded(%q) = (%d, %d), want (%d, %d)",
 				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
 		}
 	}
+
+	// With the default extensions neither is a library.
+	for _, input := range []string{"a.1.2.3.b", "short.1.2.3.x"} {
+		if i, j := identity.Embedded([]byte(input), identity.DefaultEmbeddedExtensions); i != 0 || j != 0 {
+			t.Errorf("Embedded(%q) = (%d, %d), want (0, 0)", input, i, j)
+		}
+	}
+}
+
+func TestDiff_EmbeddedExtensions(t *testing.T) {
+	old := []string{"doc/rfc.1.2.3.txt", "lib/foo.1.2.3.so", "data/set.1.2.3.dat"}
+	cur := []string{"doc/rfc.4.5.6.txt", "lib/foo.1.2.4.so", "data/set.1.2.4.dat"}
+
+	r := Diff(old, cur)
+	want := []Entry{
+		newEntry(0, null, Removed),
+		newEntry(1, 1, Updated),
+		newEntry(2, null, Removed),
+		newEntry(null, 0, Added),
+		newEntry(null, 2, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+
+	r = mustDiff(t, old, cur, Options{EmbeddedExtensions: []string{".so", ".dat"}})
+	want = []Entry{
+		newEntry(0, null, Removed),
+		newEntry(1, 1, Updated),
+		newEntry(2, 2, Updated),
+		newEntry(null, 0, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("EmbeddedExtensions: entries = %+v, want %+v", r.E, want)
+	}
+
+	r = mustDiff(t, old, cur, Options{EmbeddedExtensions: []string{}})
+	if r.Count(Updated) != 0 {
+		t.Errorf("empty EmbeddedExtensions: updated = %d, want 0", r.Count(Updated))
+	}
+}
+
+func TestGoBinary(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"myapp-v1.2.3-linux-amd64", 5, 12},
+		{"myapp_1.2.3_darwin_arm64", 5, 11},
+		{"myapp_1.2.3_darwin_arm64.tar.gz", 5, 11},
+		{"myapp-1.2.3-windows-amd64.exe", 5, 11},
+		{"myapp_1.2.3_linux_x86_64", 5, 11},
+		{"dist/my-app_2.0.0_freebsd_arm", 11, 17},
+		{"myapp-v1.2.3-linux", 0, 0},        // no arch
+		{"myapp-beta-linux-amd64", 0, 0},    // no version
+		{"myapp-v1.2.3-plan10-amd64", 0, 0}, // unknown os
+		{"dist/_1.2.3_linux_amd64", 0, 0},   // empty name
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.GoBinary([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("GoBinary(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_GoBinary(t *testing.T) {
+	old := []string{"myapp-v1.2.3-linux-amd64", "myapp_1.2.3_darwin_arm64.tar.gz", "myapp_1.2.3_linux_amd64.tar.gz"}
+	cur := []string{"myapp-v1.3.0-linux-amd64", "myapp_1.3.0_darwin_arm64.tar.gz", "myapp_1.3.0_linux_amd64.tar.gz"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestDebSource(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"foo_1.2.3-1.dsc", 3, 11},
+		{"foo_1.2.3.orig.tar.gz", 3, 9},
+		{"foo_1.2.3-1.debian.tar.xz", 3, 11},
+		{"foo_1.2.3-1.diff.gz", 3, 11},
+		{"pool/main/n/nginx/nginx_1.24.0-1~deb12u1.dsc", 23, 40},
+		{"foo.dsc", 0, 0},          // no version
+		{"foo_bar.dsc", 0, 0},      // version must start with a digit
+		{"foo_1.2.3.tar.gz", 0, 0}, // not a source component
+		{"dir/_1.2.3.dsc", 0, 0},   // empty name
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.DebSource([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("DebSource(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_DebSource(t *testing.T) {
+	old := []string{"foo_1.2.3-1.dsc", "foo_1.2.3.orig.tar.gz", "foo_1.2.3-1.debian.tar.xz"}
+	cur := []string{"foo_1.3.0-2.dsc", "foo_1.3.0.orig.tar.gz", "foo_1.3.0-2.debian.tar.xz"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestConda(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"numpy-1.26.4-py312h1234567_0.conda", 5},
+		{"numpy-2.0.0-py312habcdef0_1.tar.bz2", 5},
+		{"ca-certificates-2024.2.2-h06a4308_0.conda", 15},
+		{"tzdata-2024a-h0c530f3_0.conda", 6},
+		{"pkgs/main/linux-64/openssl-3.0.13-h7f8727e_2.conda", 26},
+		{"numpy-1.26.4.conda", 0},       // no build string
+		{"numpy-beta-py312_0.conda", 0}, // version must start with a digit
+		{"numpy-1.26.4-py312_0.zip", 0}, // unsupported extension
+	}
+
+	for _, tt := range tests {
+		if got := identity.Conda([]byte(tt.input)); got != tt.want {
+			t.Errorf("Conda(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiff_Conda(t *testing.T) {
+	old := []string{"numpy-1.26.4-py312h1234567_0.conda", "scipy-1.11.4-py311h64a7726_0.tar.bz2"}
+	cur := []string{"numpy-2.0.0-py313h7654321_1.conda", "scipy-1.13.0-py311h517d4fd_1.tar.bz2"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestTexRevision(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"foo.r12345.tar.xz", 3, 10},
+		{"foo.doc.r12345.tar.xz", 7, 14},
+		{"archive/amsmath.r67890.tar.xz", 15, 22},
+		{"foo.r12.tar.xz", 0, 0},    // too few digits
+		{"foo-r12345.tar.xz", 0, 0}, // not dot separated
+		{"foo.r12345", 0, 0},        // no extension
+		{"dir/.r12345.tar.xz", 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.TexRevision([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("TexRevision(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+
+	if !identity.Equal("foo.r12345.tar.xz", "foo.r12346.tar.xz") {
+		t.Error("expected TeX revisions of the same package to share an identity")
+	}
+	if identity.Equal("foo.r12345.tar.xz", "bar.r12345.tar.xz") {
+		t.Error("expected different TeX packages to have different identities")
+	}
+}
+
+func TestPhp(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"Package-1.2.3.tgz", 7, 13},
+		{"Console_Getopt-1.4.3RC1.tgz", 14, 23},
+		{"monolog/monolog/3.5.0.zip", 15, 21},
+		{"cache/symfony/console/v7.0.1.zip", 21, 28},
+		{"Package-1.2.3.zip", 0, 0}, // PEAR archives are .tgz
+		{"Package-beta.tgz", 0, 0},  // version must start with a digit
+		{"monolog/3.5.0.zip", 0, 0}, // missing vendor directory
+		{"v0000.zip", 0, 0},         // no separator before the version
+		{"vabcdefg.zip", 0, 0},
+		{"v10000000.tgz", 0, 0},
+		{"images/2024/01.zip", 0, 0}, // version must be dotted
+		{"Package.tgz", 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.Php([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("Php(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_Php(t *testing.T) {
+	old := []string{"Package-1.2.3.tgz", "monolog/monolog/3.5.0.zip"}
+	cur := []string{"Package-1.3.0.tgz", "monolog/monolog/3.6.0.zip"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestBrowserExtension(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"ext-1.2.3.crx", 3, 9},
+		{"ext_1.2.3.xpi", 3, 9},
+		{"ext-v2.0.crx", 3, 8},
+		{"ublock_origin-1.55.0-an+fx.xpi", 13, 26},
+		{"ext-1.2.3-aapocclcgogkmnckokdopfmhonfmgoek.crx", 3, 42},
+		{"ext-1.2.3.zip", 0, 0}, // not an extension package
+		{"ext-beta.crx", 0, 0},  // version must start with a digit
+		{"ext-1.2..crx", 0, 0},  // version must not end with a dot
+		{"1.2.3.xpi", 0, 0},     // missing name
+		{"extension.crx", 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.BrowserExtension([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("BrowserExtension(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_BrowserExtension(t *testing.T) {
+	old := []string{"ext-1.2.3.crx", "ublock_origin-1.55.0-an+fx.xpi", "ext-1.2.3.xpi"}
+	cur := []string{"ext-1.3.0.crx", "ublock_origin-1.56.0-an+fx.xpi", "ext-2.0.0.xpi"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestErlang(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"lib/cowboy-2.10.0/ebin/cowboy.beam", 10, 17},
+		{"myapp/lib/stdlib-5.2/ebin/lists.beam", 16, 20},
+		{"lib/crypto-5.4/priv/lib/crypto.so", 10, 14},
+		{"lib/cowboy-2.10.0/README.md", 0, 0},     // not an application subdirectory
+		{"usr/lib/gcc-12/include/stddef.h", 0, 0}, // C toolchain tree, not an OTP application
+		{"lib/cowboy-2.10.0/include/cowboy.hrl", 0, 0},
+		{"mylib/cowboy-2.10.0/ebin/cowboy.beam", 0, 0},
+		{"lib/cowboy/ebin/cowboy.beam", 0, 0},
+		{"lib/cowboy-2.10./ebin/cowboy.beam", 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.Erlang([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("Erlang(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_Erlang(t *testing.T) {
+	old := []string{"cowboy-2.10.0.tar", "myapp-1.2.3.tar.gz", "lib/cowboy-2.10.0/ebin/cowboy.beam"}
+	cur := []string{"cowboy-2.12.0.tar", "myapp-1.3.0.tar.gz", "lib/cowboy-2.12.0/ebin/cowboy.beam"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestXCFramework(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"Alamofire-5.8.1.xcframework", 9, 15},
+		{"Alamofire-5.8.1.xcframework/Info.plist", 9, 15},
+		{"Frameworks/5.8.1/Alamofire.xcframework/ios-arm64/Alamofire.framework/Alamofire", 11, 17},
+		{"Frameworks/v2/Foo.xcframework/Info.plist", 11, 14},
+		{"Frameworks/Alamofire.xcframework/Info.plist", 0, 0}, // no version
+		{"5.8.1/Alamofire.xcframework/Info.plist", 0, 0},      // identity must not be empty
+		{"Foo-beta.xcframework/Info.plist", 0, 0},
+		{"Foo-1.2.3.xcframeworks/Info.plist", 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.XCFramework([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("XCFramework(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_XCFramework(t *testing.T) {
+	old := []string{
+		"Package-1.2.3.zip",
+		"Alamofire-5.8.1.xcframework/Info.plist",
+		"Frameworks/5.8.1/Alamofire.xcframework/ios-arm64/Alamofire.framework/Alamofire",
+	}
+	cur := []string{
+		"Package-1.3.0.zip",
+		"Alamofire-5.9.0.xcframework/Info.plist",
+		"Frameworks/5.9.0/Alamofire.xcframework/ios-arm64/Alamofire.framework/Alamofire",
+	}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestAssetPack(t *testing.T) {
+	tests := []struct {
+		input string
+		exts  []string
+		wantI int
+		wantJ int
+	}{
+		{"pack_v1.2.3.zip", identity.DefaultAssetExtensions, 4, 11},
+		{"pack_1.2.zip", identity.DefaultAssetExtensions, 4, 8},
+		{"textures-2.0.pak", identity.DefaultAssetExtensions, 8, 12},
+		{"sounds.v3.bank", identity.DefaultAssetExtensions, 6, 9},
+		{"mods/pack-v10.pak", identity.DefaultAssetExtensions, 9, 13},
+		{"sounds.3.bank", identity.DefaultAssetExtensions, 0, 0}, // "." requires the "v" prefix
+		{"pack_v1.2.3.pk3", identity.DefaultAssetExtensions, 0, 0},
+		{"pack_v1.2.3.pk3", []string{".pk3"}, 4, 11},
+		{"pack_v1.2.3.zip", nil, 0, 0},
+		{"pack_v.zip", identity.DefaultAssetExtensions, 0, 0},
+		{"v1.2.zip", identity.DefaultAssetExtensions, 0, 0},
+		{"pack_1.2..zip", identity.DefaultAssetExtensions, 0, 0},
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.AssetPack([]byte(tt.input), tt.exts)
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("AssetPack(%q, %q) = (%d, %d), want (%d, %d)",
+				tt.input, tt.exts, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+func TestDiff_AssetPack(t *testing.T) {
+	old := []string{"pack_v1.2.3.zip", "textures-2.0.pak", "sounds.v3.bank", "pack_v1.2.3.pak", "maps_v1.pk3"}
+	cur := []string{"pack_v1.3.0.zip", "textures-2.1.pak", "sounds.v4.bank", "pack_v2.0.pak", "maps_v2.pk3"}
+
+	r := Diff(old, cur)
+	if r.Count(Updated) != 4 || r.Count(Removed) != 1 {
+		t.Errorf("Diff: updated=%d removed=%d, want 4 and 1", r.Count(Updated), r.Count(Removed))
+	}
+
+	r = mustDiff(t, old, cur, Options{AssetExtensions: []string{".zip", ".pak", ".bank", ".pk3"}})
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		input string
+		wantI int
+		wantJ int
+	}{
+		{"report-2024-01-01T12-00-00.html", 6, 26},
+		{"report-2024-01-02T09-30-00.html", 6, 26},
+		{"out/daily-report-2023-12-31T23-59-59.tar.gz", 16, 36},
+		{"report-2024-01-01T12-00-00", 6, 26},
+		{"report-2024-13-01T12-00-00.html", 0, 0}, // invalid month
+		{"report-2024-01-01T24-00-00.html", 0, 0}, // invalid hour
+		{"report-2024-01-01-12-00-00.html", 0, 0}, // missing "T"
+		{"my-dashed-report-name-v1.html", 0, 0},
+		{"-2024-01-01T12-00-00.html", 0, 0}, // empty name
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := identity.Timestamp([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("Timestamp(%q) = (%d, %d), want (%d, %d)",
+				tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+
+	if !identity.Equal("report-2024-01-01T12-00-00.html", "report-2024-01-02T09-30-00.html") {
+		t.Error("expected successive reports to share an identity")
+	}
+	if identity.Equal("report-2024-01-01T12-00-00.html", "report-2024-01-01T12-00-00.csv") {
+		t.Error("expected reports with different extensions to have different identities")
+	}
+}
+
+func TestImageRef(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"repo@sha256:aaa", 4},
+		{"repo:tag@sha256:bbb", 4},
+		{"cgr.dev/chainguard/static@sha256:0123456789abcdef", 25},
+		{"registry:5000/app@sha256:0123abcd", 17},
+		{"registry:5000/app:1.2.3@sha512:0123abcd", 17},
+		{"repo@sha256:", 0},      // empty digest
+		{"repo@sha256:xyz", 0},   // not hex
+		{"repo@md5:abc", 0},      // unsupported algorithm
+		{"user@example.com", 0},  // not a digest
+		{"@sha256:abc", 0},       // empty repository
+		{"dir/:tag@sha256:0", 0}, // empty repository name
+	}
+
+	for _, tt := range tests {
+		if got := identity.ImageRef([]byte(tt.input)); got != tt.want {
+			t.Errorf("ImageRef(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiff_ImageRef(t *testing.T) {
+	old := []string{"cgr.dev/chainguard/static@sha256:aaa", "ghcr.io/org/app:1.0@sha256:0123"}
+	cur := []string{"cgr.dev/chainguard/static@sha256:bbb", "ghcr.io/org/app:1.1@sha256:4567"}
+
+	r := Diff(old, cur)
+
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+func TestOpam(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"dune.3.14.0", 4},
+		{"ocaml-base-compiler.5.1.0", 19},
+		{"packages/lwt.5.7.0~beta", 12},
+		{"conf-libssl.4", 11},
+		{"ppxlib.v0.16.0", 6},
+		{"foo.1.2.3.so", 0}, // library extension
+		{"README.md", 0},
+		{"dune.", 0},
+		{"dir.1/file", 0}, // only the file name is considered
+	}
+
+	for _, tt := range tests {
+		if got := identity.Opam([]byte(tt.input)); got != tt.want {
+			t.Errorf("Opam(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,67 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+)
+
+// DiffDirs walks the oldRoot and newRoot directory trees and compares the files within them.
+// It returns the Result along with the old and new file lists it was computed from,
+// so that entry indices can be resolved to names.
+//
+// Paths are relative to their root, use "/" as the separator, and are sorted.
+// Every non-directory entry is included; symbolic links are listed but not followed.
+// Empty directories contribute no paths. The first error encountered while walking
+// either tree (including a root that does not exist or is not a directory) is returned.
+func DiffDirs(oldRoot, newRoot string) (*Result, []string, []string, error) {
+	old, err := walkFiles(oldRoot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cur, err := walkFiles(newRoot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return Diff(old, cur), old, cur, nil
+}
+
+// walkFiles returns the sorted, slash-separated paths of all non-directory entries under root.
+func walkFiles(root string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == root {
+			if !d.IsDir() {
+				return fmt.Errorf("%s: not a directory", root)
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(files)
+
+	return files, nil
+}
@@ -0,0 +1,80 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTree creates the given slash-separated files (and any parent directories) under root.
+func writeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	oldRoot, newRoot := t.TempDir(), t.TempDir()
+	writeTree(t, oldRoot, "lib/libfoo.so.1", "bin/foo", "etc/old.conf")
+	writeTree(t, newRoot, "lib/libfoo.so.2", "bin/foo", "etc/new.conf")
+
+	// Empty directories contribute no paths.
+	if err := os.MkdirAll(filepath.Join(newRoot, "var", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Symbolic links are listed but not followed.
+	if err := os.Symlink(filepath.Join(newRoot, "lib"), filepath.Join(newRoot, "lib64")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, old, cur, err := DiffDirs(oldRoot, newRoot)
+	if err != nil {
+		t.Fatalf("DiffDirs() error = %v", err)
+	}
+
+	if want := []string{"bin/foo", "etc/old.conf", "lib/libfoo.so.1"}; !slices.Equal(old, want) {
+		t.Errorf("old = %v, want %v", old, want)
+	}
+	if want := []string{"bin/foo", "etc/new.conf", "lib/libfoo.so.2", "lib64"}; !slices.Equal(cur, want) {
+		t.Errorf("cur = %v, want %v", cur, want)
+	}
+
+	if !slices.Equal(r.E, Diff(old, cur).E) {
+		t.Errorf("entries = %v, want %v", r.E, Diff(old, cur).E)
+	}
+	if r.Count(Unchanged) != 1 || r.Count(Updated) != 1 || r.Count(Removed) != 1 || r.Count(Added) != 2 {
+		t.Errorf("counts = %v, want 1 unchanged, 1 updated, 1 removed, 2 added", r.Summary())
+	}
+}
+
+func TestDiffDirs_Empty(t *testing.T) {
+	r, old, cur, err := DiffDirs(t.TempDir(), t.TempDir())
+	if err != nil || len(r.E) != 0 || old != nil || cur != nil {
+		t.Errorf("DiffDirs() = %v, %v, %v, %v, want an empty result", r, old, cur, err)
+	}
+}
+
+func TestDiffDirs_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "file")
+
+	for _, roots := range [][2]string{
+		{filepath.Join(dir, "missing"), dir},
+		{dir, filepath.Join(dir, "missing")},
+		{filepath.Join(dir, "file"), dir},
+	} {
+		if r, _, _, err := DiffDirs(roots[0], roots[1]); err == nil || r != nil {
+			t.Errorf("DiffDirs(%q, %q) = %v, %v, want an error", roots[0], roots[1], r, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package files
+
+import (
+	"runtime"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// Duplicates returns groups of indices of files which appear more than once in files.
+// Groups are ordered by the index of their first occurrence and indices within a group are ascending.
+// Exact hashes are used for lookups and verified with string comparisons to handle collisions.
+func Duplicates(files []string) [][]int {
+	_, exact := identity.HashAll(files, max(1, runtime.GOMAXPROCS(0)), seed)
+
+	first := make(map[uint64]int, len(files)) // Exact hash -> index of its first occurrence
+	group := make(map[int]int)                // Index of a first occurrence -> position in groups
+
+	// Distinct strings whose exact hashes collided are tracked by value.
+	var collided map[string]int
+	var groups [][]int
+
+	for i, h := range exact {
+		j, ok := first[h]
+		if !ok {
+			first[h] = i
+			continue
+		}
+
+		if files[j] != files[i] {
+			if collided == nil {
+				collided = make(map[string]int)
+			}
+			if j, ok = collided[files[i]]; !ok {
+				collided[files[i]] = i
+				continue
+			}
+		}
+
+		g, ok := group[j]
+		if !ok {
+			g = len(groups)
+			group[j] = g
+			groups = append(groups, []int{j})
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	return groups
+}
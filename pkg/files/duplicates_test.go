@@ -0,0 +1,25 @@
+package files
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDuplicates(t *testing.T) {
+	files := []string{"a.txt", "lib.so.1", "a.txt", "b.txt", "lib.so.1", "a.txt", "lib.so.2", "c.txt"}
+
+	got := Duplicates(files)
+	want := [][]int{{0, 2, 5}, {1, 4}}
+
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Duplicates() = %v, want %v", got, want)
+	}
+
+	if got := Duplicates([]string{"a", "b", "c"}); len(got) != 0 {
+		t.Errorf("Duplicates() = %v, want no groups", got)
+	}
+
+	if got := Duplicates(nil); len(got) != 0 {
+		t.Errorf("Duplicates(nil) = %v, want no groups", got)
+	}
+}
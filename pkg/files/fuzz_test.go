@@ -2,9 +2,11 @@ package files
 
 import (
 	"hash/maphash"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/egibs/reconcile/internal/identity"
 )
@@ -48,6 +50,9 @@ func FuzzDiffMain(f *testing.F) {
 		// Unicode and special characters
 		{"café-1.0.0", "café-2.0.0"},
 		{"文件-1.0", "文件-2.0"},
+		{"app-１.０", "app-２.０"},
+		{"app-١.٠", "app-٢.٠"},
+		{"lib文件.so.1", "lib文件.so.2"},
 		// Long filenames
 		{"very-long-package-name-with-many-parts-1.2.3-r99", "very-long-package-name-with-many-parts-1.2.4-r0"},
 	}
@@ -72,7 +77,7 @@ func FuzzDiffMain(f *testing.F) {
 }
 
 // FuzzDiffMulti tests reconciliation with multiple files to exercise
-// concurrency, sharding, deduplication, and match tracking.
+// concurrency, the lookup table, deduplication, and match tracking.
 func FuzzDiffMulti(f *testing.F) {
 	// Seed with various multi-file scenarios
 	f.Add("libfoo.so.1\nlibbar.so.2", "libfoo.so.2\nlibbar.so.3\nlibnew.so.1")
@@ -115,19 +120,19 @@ func FuzzDiffMulti(f *testing.F) {
 		// Validate entries have valid indices and statuses
 		for status, e := range res.All() {
 			switch status {
-			case Unchanged, Updated:
-				if e.Old == null || e.New == null {
+			case Unchanged, Updated, ContentChanged:
+				if e.Old() == null || e.New() == null {
 					t.Errorf("unchanged/updated entry has null index: %+v", e)
 				}
-				if int(e.Old) >= len(old) || int(e.New) >= len(cur) {
+				if int(e.Old()) >= len(old) || int(e.New()) >= len(cur) {
 					t.Errorf("entry index out of bounds: %+v, old=%d, new=%d", e, len(old), len(cur))
 				}
 			case Removed:
-				if e.Old == null || e.New != null {
+				if e.Old() == null || e.New() != null {
 					t.Errorf("removed entry has wrong indices: %+v", e)
 				}
 			case Added:
-				if e.Old != null || e.New == null {
+				if e.Old() != null || e.New() == null {
 					t.Errorf("added entry has wrong indices: %+v", e)
 				}
 			}
@@ -136,12 +141,12 @@ func FuzzDiffMulti(f *testing.F) {
 }
 
 // FuzzDiffConcurrent tests reconciliation under concurrent execution
-// to catch race conditions in sharding and bitset operations.
-// Note: Results may differ when inputs contain duplicate identities due to
-// non-deterministic worker ordering in map construction.
-// Each result is verified separately rather than across calls.
+// to catch race conditions in the lookup table and bitset operations.
+// Results must be identical across calls and worker counts, even when inputs
+// contain duplicate identities.
 func FuzzDiffConcurrent(f *testing.F) {
 	f.Add("a-1.0\nb-2.0\nc-3.0\nd-4.0", "a-1.1\nb-2.1\nc-3.1\nd-4.1\ne-5.0")
+	f.Add("a-1.0\na-1.1\na-1.2\na-1.0", "a-2.0\na-1.0\na-2.1")
 
 	f.Fuzz(func(t *testing.T, oldStr, newStr string) {
 		old := splitNonEmpty(oldStr)
@@ -155,11 +160,17 @@ func FuzzDiffConcurrent(f *testing.F) {
 			wg.Add(1)
 			go func(idx int) {
 				defer wg.Done()
-				results[idx] = Diff(old, cur)
+				results[idx] = DiffN(old, cur, 1<<idx)
 			}(i)
 		}
 		wg.Wait()
 
+		for i, res := range results[1:] {
+			if res != nil && results[0] != nil && !slices.Equal(res.E, results[0].E) {
+				t.Errorf("result[%d] differs from result[0]", i+1)
+			}
+		}
+
 		// Validate each result independently
 		for i, res := range results {
 			if res == nil {
@@ -192,6 +203,14 @@ func FuzzEqual(f *testing.F) {
 		{"foo.1.2.3.so", "foo.4.5.6.so"},
 		{"app-1.0.0-r5", "app-2.0.0-r0"},
 		{"pkg-1.0.Q1abc.post-install", "pkg-2.0.Q1xyz.post-install"},
+		{"libcrypto-1_1-x64.dll", "libcrypto-3-x64.dll"},
+		{"vcruntime140_1.dll", "vcruntime140.dll"},
+		{"libfoo.1.dylib", "libfoo.2.dylib"},
+		{"Foo.framework/Versions/A/Foo", "Foo.framework/Versions/B/Foo"},
+		{"nokogiri-1.16.0-x86_64-linux.gem", "nokogiri-1.17.0-x86_64-linux.gem"},
+		{"serde-1.0.197.crate", "serde-1.0.198.crate"},
+		{"libserde-abcdef0123456789.rlib", "libserde-9876543210fedcba.rlib"},
+		{"newtonsoft.json.13.0.3.nupkg", "newtonsoft.json.13.0.4.nupkg"},
 		// Different identity
 		{"libfoo.so.1", "libbar.so.1"},
 		{"a.txt", "b.txt"},
@@ -208,6 +227,11 @@ func FuzzEqual(f *testing.F) {
 		{"foo-", "foo-"},
 		{"-1.0", "-2.0"},
 		{"x.so.", "x.so."},
+		// Unicode
+		{"café-1.0.0", "café-2.0.0"},
+		{"文件-1.0", "文件-2.0"},
+		{"app-１.０", "app-２.０"},
+		{"app-１.０", "APP-１.０"},
 	}
 
 	for _, c := range cases {
@@ -226,11 +250,16 @@ func FuzzEqual(f *testing.F) {
 		if eq != identity.Equal(b, a) {
 			t.Errorf("symmetry violated: Equal(%q, %q) != Equal(%q, %q)", a, b, b, a)
 		}
+
+		// Names with the same identity have the same Identity string
+		if eq && identity.Identity(a) != identity.Identity(b) {
+			t.Errorf("Equal(%q, %q) = true but Identity = %q, %q", a, b, identity.Identity(a), identity.Identity(b))
+		}
 	})
 }
 
 // FuzzSpans tests the Spans function which determines identity boundaries.
-// This validates pattern priority: Soname > Script > Embedded > Suffix > direct.
+// This validates pattern priority as ordered in identity.Spans (e.g., Soname > Script > Embedded > Suffix > direct).
 func FuzzSpans(f *testing.F) {
 	cases := []string{
 		// Soname (highest priority)
@@ -243,7 +272,80 @@ func FuzzSpans(f *testing.F) {
 		// Embedded
 		"foo.1.2.3.so",
 		"bar.4.5.6.dylib",
+		// Dll
+		"api-ms-win-core-file-l1-2-0.dll",
+		"vcruntime140_1.dll",
+		"libcrypto-3-x64.dll",
+		"-1-x64.DLL",
+		// Dylib
+		"libfoo.1.dylib",
+		"Foo.framework/Versions/A/Foo",
+		"Foo.framework/Versions/Current/Foo",
+		"x.framework/Versions/",
+		// GoBinary
+		"myapp-v1.2.3-linux-amd64",
+		"myapp_1.2.3_darwin_arm64.tar.gz",
+		// Deb
+		"nginx_1.24.0-1_amd64.deb",
+		"libc6_1:2.36-9_arm64.deb",
+		"libc6_1%3a2.36-9_arm64.deb",
+		"_1%3_a.deb",
+		// Rpm
+		"bash-5.2.15-1.fc39.x86_64.rpm",
+		"libfoo2-1.0-1.x86_64.rpm",
+		"bash-5.2.15-1.fc39.src.rpm",
+		"-1-1.x.rpm",
+		// Jar
+		"guava-33.0.0-jre.jar",
+		"commons-lang3-3.14.0.jar",
+		"foo-1.2.3-sources.jar",
+		"a-1-tests.ear",
+		// GoModule
+		"github.com/foo/bar/@v/v1.2.3.zip",
+		"github.com/foo/bar/v2/@v/v2.0.1.mod",
+		"github.com/foo/bar/@v/v0.0.0-20230101000000-abcdef123456.info",
+		"x/@v/v1.lock",
+		// Gem
+		"rails-7.1.3.gem",
+		"nokogiri-1.16.0-x86_64-linux.gem",
+		"rack-3.1.0.beta1.gem",
+		"a-1-.gem",
+		// Crate
+		"serde-1.0.197.crate",
+		"libserde-abcdef0123456789.rlib",
+		"-1.0.crate",
+		"x/-01234567.rmeta",
+		// Nupkg
+		"newtonsoft.json.13.0.3.nupkg",
+		"microsoft.netcore.app.ref.8.0.0-preview.7.23375.6.nupkg",
+		"a.1.2-.nupkg",
+		"/.0.0.snupkg",
+		// Npm
+		"lodash-4.17.21.tgz",
+		"@babel/core-7.24.0.tgz",
+		"babel-plugin-foo-1.2.3-beta.1.tgz",
+		"@/-1.1.tgz",
+		// ContentHash
+		"main.4f3a9c1b.js",
+		"app.9f8e7d6c.chunk.js",
+		"main.4f3a9c1b.js.map",
+		"a.00000000.bundle.css",
+		// Kmod
+		"nvidia-535.154.05.ko",
+		"zfs-2.2.2.ko.zst",
+		"i915.ko.xz",
+		"a-1.ko.gz",
+		// DateStamp
+		"myapp-nightly-20250114.tar.gz",
+		"snapshot.20250114-235959.img",
+		"x.19000101",
+		"x.20991231-000000",
+		"x.20250132",
+		"x-20250229-240000",
 		// Suffix
+		"pkg-2:1.2.3-r0",
+		"pkg2:1.2.3",
+		"-:1",
 		"app-1.0.0-r5",
 		"tool-2.3.4",
 		// Direct (no pattern)
@@ -258,6 +360,14 @@ func FuzzSpans(f *testing.F) {
 		// Ambiguous cases
 		"libfoo.so.1.2.3.so",              // Should match soname
 		"foo-1.0.Q1abc.post-install.so.1", // Multiple patterns
+		// Unicode: only ASCII digits are versions
+		"café-1.0.0",
+		"文件-1.0",
+		"app-１.２.３",
+		"app-١.٢",
+		"lib文件.so.1",
+		"文件.1.2.3.so",
+		"é-\xff1.0",
 	}
 
 	for _, c := range cases {
@@ -283,6 +393,11 @@ func FuzzSpans(f *testing.F) {
 		if s > 0 && (e < s || e > length) {
 			t.Errorf("Spans(%q): invalid second span s=%d, e=%d, len=%d", input, s, e, length)
 		}
+
+		// Boundaries never split a multi-byte rune
+		if utf8.ValidString(input) && (!utf8.Valid(bs[:j]) || !utf8.Valid(bs[s:e])) {
+			t.Errorf("Spans(%q) = (%d, %d, %d): span splits a UTF-8 sequence", input, j, s, e)
+		}
 	})
 }
 
@@ -552,6 +667,10 @@ func FuzzSuffix(f *testing.F) {
 		"bar-beta",
 		"-1.0",
 		"",
+		// Unicode digits are not versions
+		"app-１.０",
+		"app-١.٠",
+		"café-1.0",
 	}
 
 	for _, c := range cases {
@@ -593,7 +712,20 @@ func FuzzEmbedded(f *testing.F) {
 		"man_page.7",
 		"",
 		"a.b.c",
-		"short.1.2.3.x", // too short total
+		"short.1.2.3.x", // ".x" is not a library extension
+		// Numbered documents are not libraries
+		"rfc.1.2.3.txt",
+		"chapter.1.2.3.html",
+		"docs/manual.2.0.1.pdf",
+		"man/man3/foo.1.2.3.3",
+		// Unicode
+		"文件.1.2.3.so",
+		"foo.١.٢.٣.so",
+		// Near the minimum length
+		"a.1.2.3.so",
+		"ab.1.2.so",
+		".1.2.3.so",
+		"a..1.2.so",
 	}
 
 	for _, c := range cases {
@@ -601,7 +733,7 @@ func FuzzEmbedded(f *testing.F) {
 	}
 
 	f.Fuzz(func(t *testing.T, input string) {
-		i, j := identity.Embedded([]byte(input))
+		i, j := identity.Embedded([]byte(input), identity.DefaultEmbeddedExtensions)
 
 		if i < 0 || j < 0 {
 			t.Errorf("Embedded(%q) = (%d, %d): negative return", input, i, j)
@@ -614,6 +746,10 @@ func FuzzEmbedded(f *testing.F) {
 		if i > 0 && j <= i {
 			t.Errorf("Embedded(%q) = (%d, %d): end must be greater than start", input, i, j)
 		}
+
+		if i > 0 && (input[i] != '.' || j >= len(input) || input[j] != '.') {
+			t.Errorf("Embedded(%q) = (%d, %d): version must lie between dots", input, i, j)
+		}
 	})
 }
 
@@ -0,0 +1,17 @@
+package files
+
+import "github.com/egibs/reconcile/internal/identity"
+
+// Group returns the indices of files keyed by their identity (see Identity), so all versions
+// of a file present in one list share a group (e.g., "libssl.so.1" and "libssl.so.3").
+// Indices within a group are ascending. Groups are keyed by the identity string itself
+// rather than its hash, so distinct identities never share a group.
+func Group(files []string) map[string][]int {
+	groups := make(map[string][]int)
+	for i, f := range files {
+		id := identity.Identity(f)
+		groups[id] = append(groups[id], i)
+	}
+
+	return groups
+}
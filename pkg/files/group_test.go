@@ -0,0 +1,38 @@
+package files
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+func TestGroup(t *testing.T) {
+	files := []string{"libssl.so.1", "libssl.so.3", "libcrypto.so.1"}
+
+	got := Group(files)
+	want := map[string][]int{
+		"libssl.so":    {0, 1},
+		"libcrypto.so": {2},
+	}
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Group() = %v, want %v", got, want)
+	}
+
+	// Files in a group are Equal to each other and to no file in another group.
+	files = []string{"app-1.0.0", "lib/foo.1.2.3.so", "app-2.0.0-r1", "README.md", "lib/foo.1.2.4.so", "README.md"}
+	for _, g := range Group(files) {
+		for _, i := range g {
+			for j := range files {
+				if identity.Equal(files[i], files[j]) != slices.Contains(g, j) {
+					t.Errorf("Equal(%q, %q) disagrees with group %v", files[i], files[j], g)
+				}
+			}
+		}
+	}
+
+	if got := Group(nil); len(got) != 0 {
+		t.Errorf("Group(nil) = %v, want no groups", got)
+	}
+}
@@ -0,0 +1,132 @@
+package files
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ignoring reports whether any paths may be ignored.
+func (o *Options) ignoring() bool {
+	return o.Ignore != nil || len(o.IgnorePatterns) > 0
+}
+
+// checkPatterns returns an error for the first malformed pattern in IgnorePatterns.
+func (o *Options) checkPatterns() error {
+	for _, p := range o.IgnorePatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// ignored reports whether p is ignored by Ignore or IgnorePatterns.
+// Patterns without a "/" are matched against each segment of p and
+// other patterns against p as a whole. The patterns must be valid.
+func (o *Options) ignored(p string) bool {
+	if o.Ignore != nil && o.Ignore(p) {
+		return true
+	}
+
+	for _, pattern := range o.IgnorePatterns {
+		if strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+			continue
+		}
+
+		for seg := range strings.SplitSeq(p, "/") {
+			if ok, _ := path.Match(pattern, seg); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// filter returns the files which are not ignored along with their original indices.
+func (o *Options) filter(files []string) ([]string, []uint32) {
+	kept := make([]string, 0, len(files))
+	idx := make([]uint32, 0, len(files))
+
+	for i, f := range files {
+		if !o.ignored(f) {
+			kept = append(kept, f)
+			idx = append(idx, uint32(i)) // #nosec G115
+		}
+	}
+
+	return kept, idx
+}
+
+// filterInputs removes ignored files from old and cur and rewrites the options aligned
+// with them (content hashes and trusted pairs) to refer to the remaining files.
+// It returns the remaining files and their original indices.
+func (o *Options) filterInputs(old, cur []string) ([]string, []string, []uint32, []uint32) {
+	oldFiles, newFiles := len(old), len(cur)
+	content := o.hasContent(oldFiles, newFiles)
+
+	old, oldIdx := o.filter(old)
+	cur, curIdx := o.filter(cur)
+
+	if content {
+		o.OldContent = pick(o.OldContent, oldIdx)
+		o.CurContent = pick(o.CurContent, curIdx)
+	}
+
+	if len(o.TrustedPairs) > 0 {
+		oldPos, curPos := positions(oldIdx, oldFiles), positions(curIdx, newFiles)
+		pairs := make([]IndexPair, 0, len(o.TrustedPairs))
+		for _, p := range o.TrustedPairs {
+			if int(p.Old) < oldFiles && int(p.New) < newFiles && oldPos[p.Old] != null && curPos[p.New] != null {
+				pairs = append(pairs, IndexPair{Old: oldPos[p.Old], New: curPos[p.New]})
+			}
+		}
+		o.TrustedPairs = pairs
+	}
+
+	return old, cur, oldIdx, curIdx
+}
+
+// pick returns the values at the given indices.
+func pick(values []uint64, idx []uint32) []uint64 {
+	out := make([]uint64, len(idx))
+	for i, j := range idx {
+		out[i] = values[j]
+	}
+
+	return out
+}
+
+// positions inverts idx, returning the position of each of n original indices within idx
+// (or null when it is absent).
+func positions(idx []uint32, n int) []uint32 {
+	pos := make([]uint32, n)
+	for i := range pos {
+		pos[i] = null
+	}
+	for i, j := range idx {
+		pos[j] = uint32(i) // #nosec G115
+	}
+
+	return pos
+}
+
+// restoreIndices rewrites the entries of r, which refer to filtered file lists,
+// to refer to the original file lists instead.
+func restoreIndices(r *Result, oldIdx, curIdx []uint32) {
+	for i, e := range r.E {
+		o, n := e.Old(), e.New()
+		if o != null {
+			o = oldIdx[o]
+		}
+		if n != null {
+			n = curIdx[n]
+		}
+		r.E[i] = newEntry(o, n, e.Status())
+	}
+}
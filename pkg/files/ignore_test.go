@@ -0,0 +1,91 @@
+package files
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	opts := &Options{
+		Ignore:         func(p string) bool { return strings.HasSuffix(p, ".log") },
+		IgnorePatterns: []string{"*.pyc", "__pycache__", "var/cache/*"},
+	}
+
+	tests := map[string]bool{
+		"app.py":                            false,
+		"app.pyc":                           true,
+		"lib/python3/site/mod.pyc":          true,
+		"lib/python3/__pycache__/mod.py":    true,
+		"var/log/app-2024-01-01.log":        true,
+		"var/cache/apt":                     true,
+		"var/cache/apt/pkgcache.bin":        false, // "*" does not match "/"
+		"opt/var/cache/x":                   false,
+		"usr/lib/libfoo.so.1":               false,
+		"usr/share/doc/pyc/README.pycfiles": false,
+	}
+
+	for p, want := range tests {
+		if got := opts.ignored(p); got != want {
+			t.Errorf("ignored(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestDiffWithOptions_IgnorePatterns(t *testing.T) {
+	old := []string{"app/main.pyc", "app/main.py", "app/__pycache__/util.cpython-311.pyc", "usr/lib/libfoo.so.1", "old.txt"}
+	cur := []string{"app/main.py", "usr/lib/libfoo.so.2", "app/main.pyc", "new.txt", "app/extra.pyc"}
+
+	r := mustDiff(t, old, cur, Options{IgnorePatterns: []string{"*.pyc"}})
+	want := []Entry{
+		newEntry(1, 0, Unchanged),
+		newEntry(3, 1, Updated),
+		newEntry(4, null, Removed),
+		newEntry(null, 3, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+
+	counts := [numStatuses]uint32{Unchanged: 1, Updated: 1, Removed: 1, Added: 1}
+	for s := range numStatuses {
+		if got := r.C[s].Load(); got != counts[s] {
+			t.Errorf("count %v = %d, want %d", Status(s), got, counts[s])
+		}
+	}
+
+	// A predicate ignores the same files.
+	p := mustDiff(t, old, cur, Options{Ignore: func(p string) bool { return path.Ext(p) == ".pyc" }})
+	if !slices.Equal(p.E, want) {
+		t.Errorf("Ignore entries = %+v, want %+v", p.E, want)
+	}
+}
+
+func TestDiffWithOptions_IgnoreAligned(t *testing.T) {
+	old := []string{"a.pyc", "etc/app.conf", "b.pyc", "etc/old.conf"}
+	cur := []string{"etc/new.conf", "c.pyc", "etc/app.conf"}
+
+	r := mustDiff(t, old, cur, Options{
+		IgnorePatterns: []string{"*.pyc"},
+		OldContent:     []uint64{0, 1, 0, 2},
+		CurContent:     []uint64{2, 0, 3},
+		DetectRenames:  true,
+		TrustedPairs:   []IndexPair{{Old: 0, New: 1}},
+	})
+	want := []Entry{
+		newEntry(1, 2, ContentChanged),
+		newEntry(3, 0, Renamed),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_IgnoreInvalidPattern(t *testing.T) {
+	_, err := DiffWithOptions([]string{"a"}, []string{"b"}, Options{IgnorePatterns: []string{"["}})
+	if !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("error = %v, want %v", err, path.ErrBadPattern)
+	}
+}
@@ -0,0 +1,105 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// resultJSON is the JSON representation of a Result.
+type resultJSON struct {
+	Entries []entryJSON       `json:"entries"`
+	Counts  map[Status]uint32 `json:"counts"`
+}
+
+// entryJSON is the JSON representation of an entry within a Result.
+// The missing side of Removed and Added entries is omitted.
+type entryJSON struct {
+	Old     *uint32 `json:"old,omitempty"`
+	New     *uint32 `json:"new,omitempty"`
+	Status  Status  `json:"status"`
+	OldName string  `json:"old_name,omitempty"`
+	NewName string  `json:"new_name,omitempty"`
+}
+
+// MarshalJSON encodes the result as an object holding its entries, with status names
+// as encoded by Status.MarshalText (e.g., "updated"), and the count of each status.
+// IdentityHashes and Dirs are not included.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toJSON(nil, nil))
+}
+
+// MarshalJSONNamed is like MarshalJSON but also includes the old and new file names
+// of each entry, resolved against the original old and cur slices.
+func (r *Result) MarshalJSONNamed(old, cur []string) ([]byte, error) {
+	return json.Marshal(r.toJSON(old, cur))
+}
+
+// toJSON converts the result to its JSON representation, resolving names when old and cur are set.
+func (r *Result) toJSON(old, cur []string) resultJSON {
+	out := resultJSON{
+		Entries: make([]entryJSON, len(r.E)),
+		Counts:  make(map[Status]uint32, numStatuses),
+	}
+
+	for s := range numStatuses {
+		out.Counts[Status(s)] = r.C[s].Load() // #nosec G115
+	}
+
+	for i, e := range r.E {
+		j := &out.Entries[i]
+		j.Status = e.Status()
+
+		if o := e.Old(); o != null {
+			j.Old = &o
+			if old != nil {
+				j.OldName = old[o]
+			}
+		}
+		if n := e.New(); n != null {
+			j.New = &n
+			if cur != nil {
+				j.NewName = cur[n]
+			}
+		}
+	}
+
+	return out
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON or MarshalJSONNamed.
+// File names are ignored.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var in resultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	entries := make([]Entry, len(in.Entries))
+	for i, j := range in.Entries {
+		s := j.Status
+		o, n := null, null
+		if j.Old != nil {
+			o = *j.Old
+		}
+		if j.New != nil {
+			n = *j.New
+		}
+		if (o == null) != (s == Added) || (n == null) != (s == Removed) || n != null && n >= maxNewFiles {
+			return fmt.Errorf("invalid entry %d: indices do not match status %q", i, j.Status)
+		}
+
+		entries[i] = newEntry(o, n, s)
+	}
+
+	var counts [numStatuses]uint32
+	for s, c := range in.Counts {
+		counts[s] = c
+	}
+
+	r.E = entries
+	for s := range numStatuses {
+		r.C[s].Store(counts[s])
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package files
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResult_JSONRoundTrip(t *testing.T) {
+	old, cur := genMixed(1_000)
+
+	for _, r := range []*Result{Diff(old, cur), Diff(nil, cur), Diff(old, nil), Diff(nil, nil)} {
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+
+		var got Result
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if len(got.E) != len(r.E) || !slices.Equal(got.E, r.E) {
+			t.Error("entries differ after round trip")
+		}
+		for s := range numStatuses {
+			if got.C[s].Load() != r.C[s].Load() {
+				t.Errorf("count %d = %d, want %d", s, got.C[s].Load(), r.C[s].Load())
+			}
+		}
+	}
+}
+
+func TestResult_MarshalJSON(t *testing.T) {
+	old := []string{"lib/libfoo.so.1", "old.txt"}
+	cur := []string{"lib/libfoo.so.2", "new.txt"}
+
+	r := Diff(old, cur)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"entries":[{"old":0,"new":0,"status":"updated"},{"old":1,"status":"removed"},{"new":1,"status":"added"}],` +
+		`"counts":{"added":1,"content_changed":0,"removed":1,"renamed":0,"unchanged":0,"updated":1}}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	data, err = r.MarshalJSONNamed(old, cur)
+	if err != nil {
+		t.Fatalf("MarshalJSONNamed() error = %v", err)
+	}
+	for _, s := range []string{`"old_name":"lib/libfoo.so.1","new_name":"lib/libfoo.so.2"`, `"old_name":"old.txt"}`, `"new_name":"new.txt"}`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("MarshalJSONNamed() = %s, missing %s", data, s)
+		}
+	}
+
+	// Named output decodes like the plain output.
+	var got Result
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !slices.Equal(got.E, r.E) {
+		t.Errorf("entries = %v, want %v", got.E, r.E)
+	}
+}
+
+func TestResult_MarshalJSONStatus(t *testing.T) {
+	r := Diff([]string{"a-1.0", "b", "c"}, []string{"a-2.0", "b", "d"})
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	// The status names in the report decode as a Status.
+	var got struct {
+		Entries []struct{ Status Status }
+		Counts  map[Status]uint32
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", data, err)
+	}
+	for i, e := range got.Entries {
+		if e.Status != r.E[i].Status() {
+			t.Errorf("entry %d: status = %v, want %v", i, e.Status, r.E[i].Status())
+		}
+	}
+	for s := range numStatuses {
+		if got.Counts[Status(s)] != r.C[s].Load() {
+			t.Errorf("count %v = %d, want %d", Status(s), got.Counts[Status(s)], r.C[s].Load())
+		}
+	}
+}
+
+func TestResult_UnmarshalJSONInvalid(t *testing.T) {
+	tests := []string{
+		`{"entries":[{"old":0,"new":0,"status":"moved"}]}`, // unknown status
+		`{"entries":[{"old":0,"status":"updated"}]}`,       // missing new index
+		`{"entries":[{"old":0,"new":0,"status":"added"}]}`, // added with an old index
+		`{"entries":[{"new":0,"status":"removed"}]}`,       // removed without an old index
+		`{"entries":[{"old":0,"new":4294967295,"status":"unchanged"}]}`,
+		`{"counts":{"moved":1}}`,
+		`[]`,
+	}
+
+	for _, data := range tests {
+		var r Result
+		if err := json.Unmarshal([]byte(data), &r); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want error", data)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// manifestRule is a parsed manifest line.
+type manifestRule struct {
+	segments []string
+	negate   bool
+}
+
+// ExpandManifest resolves manifest lines against fsys into the sorted list of files
+// they select, suitable as input to Diff.
+//
+// Each line is a slash-separated glob pattern using path.Match syntax for each path
+// segment, where a "**" segment matches zero or more directories (e.g., "lib/**").
+// A line starting with "!" excludes the files it matches (e.g., "!lib/test/**").
+// Later lines take precedence over earlier ones, so a file is selected when the last
+// line matching it is not a negation. Blank lines and lines starting with "#" are ignored.
+func ExpandManifest(lines []string, fsys fs.FS) ([]string, error) {
+	var rules []manifestRule
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		r := manifestRule{}
+		if line[0] == '!' {
+			r.negate = true
+			line = line[1:]
+		}
+
+		r.segments = strings.Split(strings.Trim(line, "/"), "/")
+		for _, seg := range r.segments {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("invalid manifest pattern %q: %w", line, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		segments := strings.Split(p, "/")
+		selected := false
+		for i := len(rules) - 1; i >= 0; i-- {
+			if matchSegments(rules[i].segments, segments) {
+				selected = !rules[i].negate
+				break
+			}
+		}
+
+		if selected {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// matchSegments reports whether the path segments match the pattern segments,
+// with "**" matching zero or more path segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,83 @@
+package files
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExpandManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bin/tool":                   {},
+		"lib/libfoo.so.1":            {},
+		"lib/test/fixture.so":        {},
+		"lib/test/keep/important.so": {},
+		"lib/x/y/libbar.so.2":        {},
+		"share/doc/README":           {},
+		"share/doc/README.md":        {},
+	}
+
+	lines := []string{
+		"# runtime files",
+		"lib/**",
+		"!lib/test/**",
+		"lib/test/keep/*.so", // re-included after the broader negation
+		"",
+		"share/**/*.md",
+		"bin/*",
+		"!bin/tool",
+	}
+
+	got, err := ExpandManifest(lines, fsys)
+	if err != nil {
+		t.Fatalf("ExpandManifest() error = %v", err)
+	}
+
+	want := []string{
+		"lib/libfoo.so.1",
+		"lib/test/keep/important.so",
+		"lib/x/y/libbar.so.2",
+		"share/doc/README.md",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ExpandManifest() = %q, want %q", got, want)
+	}
+
+	// Reversing the include and exclude order changes the precedence.
+	got, err = ExpandManifest([]string{"!lib/test/**", "lib/**"}, fsys)
+	if err != nil {
+		t.Fatalf("ExpandManifest() error = %v", err)
+	}
+	if len(got) != 4 {
+		t.Errorf("ExpandManifest() = %q, want all 4 lib files", got)
+	}
+}
+
+func TestExpandManifest_InvalidPattern(t *testing.T) {
+	if _, err := ExpandManifest([]string{"lib/[a-"}, fstest.MapFS{}); err == nil {
+		t.Error("ExpandManifest() error = nil, want error")
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"**", "a/b/c", true},
+		{"a/**", "a", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/b/x/c", true},
+		{"a/**/c", "a/b/x/d", false},
+		{"*.so", "lib/a.so", false},
+		{"**/*.so", "lib/a.so", true},
+	}
+
+	for _, tt := range tests {
+		got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/"))
+		if got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownOrder is the order in which status groups appear in a markdown table.
+var markdownOrder = [...]Status{Updated, ContentChanged, Renamed, Removed, Added}
+
+// markdownEscaper escapes characters which would break a markdown table cell.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", " ")
+
+// WriteMarkdown writes a GitHub-flavored markdown summary of the result using the
+// original file lists. A summary line with the count of every status is followed by
+// a table with Status, Old, and New columns grouped by status (Updated, ContentChanged,
+// Renamed, Removed, then Added). Unchanged files are only included in the summary line,
+// and the table is omitted when nothing changed.
+func (r *Result) WriteMarkdown(old, cur []string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("**Summary:**")
+	for s := range numStatuses {
+		if s > 0 {
+			bw.WriteByte(',')
+		}
+		fmt.Fprintf(bw, " %d %s", r.C[s].Load(), strings.ReplaceAll(statusNames[s], "_", " "))
+	}
+	bw.WriteString("\n")
+
+	if r.Count(Unchanged) == uint32(len(r.E)) { // #nosec G115
+		return bw.Flush()
+	}
+
+	bw.WriteString("\n| Status | Old | New |\n| --- | --- | --- |\n")
+
+	for _, s := range markdownOrder {
+		for e := range r.Filter(s) {
+			n := e.named(old, cur)
+			fmt.Fprintf(bw, "| %s | %s | %s |\n",
+				statusNames[s], markdownEscaper.Replace(n.OldName), markdownEscaper.Replace(n.NewName))
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,44 @@
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResult_WriteMarkdown(t *testing.T) {
+	old := []string{"new.txt", "libfoo.so.1", "README", "a|b.txt"}
+	cur := []string{"libfoo.so.2", "README", "c|d.txt", "other.txt"}
+
+	var b strings.Builder
+	if err := Diff(old, cur).WriteMarkdown(old, cur, &b); err != nil {
+		t.Fatalf("WriteMarkdown() error = %v", err)
+	}
+
+	want := `**Summary:** 1 unchanged, 1 updated, 2 removed, 2 added, 0 content changed, 0 renamed
+
+| Status | Old | New |
+| --- | --- | --- |
+| updated | libfoo.so.1 | libfoo.so.2 |
+| removed | new.txt |  |
+| removed | a\|b.txt |  |
+| added |  | c\|d.txt |
+| added |  | other.txt |
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteMarkdown() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestResult_WriteMarkdownUnchanged(t *testing.T) {
+	files := []string{"a", "b"}
+
+	var b strings.Builder
+	if err := Diff(files, files).WriteMarkdown(files, files, &b); err != nil {
+		t.Fatalf("WriteMarkdown() error = %v", err)
+	}
+
+	want := "**Summary:** 2 unchanged, 0 updated, 0 removed, 0 added, 0 content changed, 0 renamed\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteMarkdown() = %q, want %q", got, want)
+	}
+}
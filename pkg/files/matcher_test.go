@@ -0,0 +1,312 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// matchers holds the built-in matchers covered by TestMatchers by name.
+var matchers = map[string]func([]byte) (int, int){
+	"ContentHash": identity.ContentHash,
+	"Crate":       identity.Crate,
+	"DateStamp":   identity.DateStamp,
+	"Deb":         identity.Deb,
+	"Dll":         identity.Dll,
+	"Dylib":       identity.Dylib,
+	"Gem":         identity.Gem,
+	"GoModule":    identity.GoModule,
+	"Jar":         identity.Jar,
+	"Kmod":        identity.Kmod,
+	"Npm":         identity.Npm,
+	"Nupkg":       identity.Nupkg,
+	"Rpm":         identity.Rpm,
+}
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		matcher      string
+		input        string
+		wantI, wantJ int
+	}{
+		{"Rpm", "bash-5.2.15-1.fc39.x86_64.rpm", 4, 25},
+		{"Rpm", "libfoo2-1.0-1.x86_64.rpm", 7, 20},
+		{"Rpm", "python3-pip-23.2.1-1.fc39.noarch.rpm", 11, 32},
+		{"Rpm", "bash-5.2.15-1.fc39.src.rpm", 4, 18},                // source packages keep their arch
+		{"Rpm", "Packages/b/bash-5.2.15-1.fc39.x86_64.rpm", 15, 36}, // repository layout
+		{"Rpm", "bash-1.x86_64.rpm", 0, 0},                          // missing release
+		{"Rpm", "bash-beta-1.x86_64.rpm", 0, 0},                     // version must start with a digit
+		{"Rpm", "bash-5.2.15-1.rpm", 0, 0},                          // missing arch
+		{"Rpm", "bash.rpm", 0, 0},
+		{"Deb", "nginx_1.24.0-1_amd64.deb", 5, 20},
+		{"Deb", "libssl3_3.0.11-1~deb12u2_amd64.deb", 7, 30},
+		{"Deb", "libc6_1:2.36-9_arm64.deb", 5, 20},   // epoch
+		{"Deb", "libc6_1%3a2.36-9_arm64.deb", 5, 22}, // escaped epoch
+		{"Deb", "pool/main/n/nginx/nginx_1.24.0-1_all.deb", 23, 36},
+		{"Deb", "my_script_v2_final", 0, 0},     // not a package
+		{"Deb", "my_script_v2_final.deb", 0, 0}, // version must start with a digit
+		{"Deb", "nginx_1:_amd64.deb", 0, 0},     // epoch without a version
+		{"Deb", "nginx_1.24.0-1.deb", 0, 0},     // missing arch
+		{"Deb", "dir/_1.2.3_amd64.deb", 0, 0},   // empty name
+		{"Npm", "lodash-4.17.21.tgz", 6, 14},
+		{"Npm", "@babel/core-7.24.0.tgz", 11, 18},
+		{"Npm", "@scope/name-1.2.3.tgz", 11, 17},
+		{"Npm", "babel-plugin-foo-1.2.3.tgz", 16, 22},
+		{"Npm", "babel-plugin-foo-1.2.3-beta.1.tgz", 16, 29}, // prerelease
+		{"Npm", "foo-1.2.3-1.tgz", 3, 11},                    // numeric build suffix stays in the version
+		{"Npm", "cache/lodash-4.17.21.tgz", 12, 20},
+		{"Npm", "lodash.tgz", 0, 0},         // no version
+		{"Npm", "lodash-4.tgz", 0, 0},       // version needs a minor component
+		{"Npm", "lodash-latest.tgz", 0, 0},  // version must start with a digit
+		{"Npm", "@scope/-1.2.3.tgz", 0, 0},  // empty name
+		{"Npm", "lodash-4.17.21.zip", 0, 0}, // not a tarball
+		{"Jar", "guava-33.0.0-jre.jar", 5, 16},
+		{"Jar", "commons-lang3-3.14.0.jar", 13, 20},
+		{"Jar", "foo-1.2.3-SNAPSHOT.jar", 3, 18},
+		{"Jar", "foo-1.2.3-sources.jar", 3, 9}, // classifier kept in the identity
+		{"Jar", "app-2.0.war", 3, 7},
+		{"Jar", "BOOT-INF/lib/spring-core-6.1.2.jar", 24, 30},
+		{"Jar", "commons-lang3.jar", 0, 0}, // no version
+		{"Jar", "foo-bar.jar", 0, 0},       // version must start with a digit
+		{"Jar", "lib/-1.0.jar", 0, 0},      // empty artifact id
+		{"Jar", "foo-1.2.3.zip", 0, 0},     // not an archive
+		{"GoModule", "github.com/foo/bar/@v/v1.2.3.zip", 24, 28},
+		{"GoModule", "github.com/foo/bar/@v/v1.2.3.info", 24, 28},
+		{"GoModule", "github.com/foo/bar/@v/v1.2.3.mod", 24, 28},
+		{"GoModule", "github.com/foo/bar/@v/v1.2.3.lock", 24, 28},
+		{"GoModule", "github.com/foo/bar/v2/@v/v2.0.1.zip", 27, 31},                           // semantic import versioning
+		{"GoModule", "github.com/foo/bar/@v/v0.0.0-20230101000000-abcdef123456.info", 24, 56}, // pseudo-version
+		{"GoModule", "github.com/foo/bar/@v/v3.1.0+incompatible.zip", 24, 41},
+		{"GoModule", "github.com/foo/bar/@v/list", 0, 0},       // no version
+		{"GoModule", "github.com/foo/bar/@v/v1.2.3.tar", 0, 0}, // unknown extension
+		{"GoModule", "github.com/foo/bar/@v/vX.2.3.zip", 0, 0}, // major must be numeric
+		{"GoModule", "github.com/foo/bar/v1.2.3/go.mod", 0, 0}, // not a cache download
+		{"GoModule", "github.com/foo/bar/@v/v1/x/y.zip", 0, 0}, // nested path
+		{"Kmod", "nvidia-535.154.05.ko", 6, 17},
+		{"Kmod", "nvidia-535.154.05.ko.xz", 6, 17},
+		{"Kmod", "zfs-2.2.2.ko.zst", 3, 9},
+		{"Kmod", "vboxdrv_7.0.ko.gz", 7, 11},
+		{"Kmod", "lib/modules/6.1.0-13-amd64/extra/nvidia-535.154.05.ko", 39, 50},
+		{"Kmod", "i915.ko.xz", 0, 0},           // no version
+		{"Kmod", "snd-hda-intel.ko", 0, 0},     // dashes without a version
+		{"Kmod", "nvidia-535.154.05.so", 0, 0}, // not a module
+		{"Kmod", "extra/-535.ko", 0, 0},        // empty name
+		{"Kmod", "nvidia-.ko", 0, 0},
+		{"DateStamp", "myapp-nightly-20250114.tar.gz", 13, 22},
+		{"DateStamp", "snapshot.20250114-235959.img", 8, 24}, // with a time
+		{"DateStamp", "snapshot.20250114-246060.img", 8, 17}, // implausible time is left in the identity
+		{"DateStamp", "backup.19991231", 6, 15},              // end of name
+		{"DateStamp", "build.20250101.20250102.log", 14, 23}, // the last date wins
+		{"DateStamp", "myapp-nightly-20251314.tar.gz", 0, 0}, // month out of range
+		{"DateStamp", "myapp-nightly-20250100.tar.gz", 0, 0}, // day out of range
+		{"DateStamp", "myapp-nightly-31415926.tar.gz", 0, 0}, // not a plausible year
+		{"DateStamp", "blob-deadbeef20250114.bin", 0, 0},     // part of a hash
+		{"DateStamp", "blob-20250114abcd.bin", 0, 0},         // not delimited
+		{"DateStamp", "builds/-20250114.tar.gz", 0, 0},       // empty name
+		{"ContentHash", "main.4f3a9c1b.js", 4, 13},
+		{"ContentHash", "vendor.ab12cd34.css", 6, 15},
+		{"ContentHash", "app.9f8e7d6c.chunk.js", 3, 12},
+		{"ContentHash", "main.4f3a9c1b.js.map", 4, 13},
+		{"ContentHash", "static/js/main.4f3a9c1b2d3e4f5a6b7c.js", 14, 35}, // 20 characters
+		{"ContentHash", "jquery.min.js", 0, 0},                            // not a hash
+		{"ContentHash", "jquery-3.7.1.min.js", 0, 0},                      // dotted version
+		{"ContentHash", "main.4f3a9c1.js", 0, 0},                          // too short
+		{"ContentHash", "main.4f3a9c1b2d3e4f5a6b7c8.js", 0, 0},            // too long
+		{"ContentHash", "main.4F3A9C1B.js", 0, 0},                         // uppercase
+		{"ContentHash", "main.4f3a9c1b.txt", 0, 0},                        // not a web asset
+		{"ContentHash", "static/.4f3a9c1b.js", 0, 0},                      // empty name
+		{"Dll", "api-ms-win-core-file-l1-2-0.dll", 23, 27},
+		{"Dll", "vcruntime140_1.dll", 12, 14},
+		{"Dll", "libcrypto-3-x64.dll", 9, 11},
+		{"Dll", "libssl-1_1-x64.dll", 6, 10},
+		{"Dll", "libcrypto-3-arm64.dll", 9, 11},
+		{"Dll", "libstdc++-6.dll", 9, 11},
+		{"Dll", "bin/LIBGCC_S_SEH-1.DLL", 16, 18},
+		{"Dll", "d3dx9_43.dll", 5, 8},
+		{"Dll", "vcruntime140.dll", 0, 0}, // digits are part of the name
+		{"Dll", "zlib1.dll", 0, 0},
+		{"Dll", "foo-x64.dll", 0, 0},   // architecture without a version
+		{"Dll", "foo.1.2.3.dll", 0, 0}, // dotted version (see Embedded)
+		{"Dll", "foo-1.2.dll", 0, 0},
+		{"Dll", "libfoo-1.so", 0, 0}, // not a DLL
+		{"Dll", "-1.dll", 0, 0},      // empty name
+		{"Dll", "bin/_1.dll", 0, 0},
+		{"Dll", "foo-.dll", 0, 0},
+		{"Dll", ".dll", 0, 0},
+		{"Dylib", "libfoo.1.dylib", 6, 8},
+		{"Dylib", "lib/libfoo2.15.dylib", 11, 14},
+		{"Dylib", "Foo.framework/Versions/A/Foo", 23, 24},
+		{"Dylib", "Library/Frameworks/Foo.framework/Versions/1.2/Resources/Info.plist", 42, 45},
+		{"Dylib", "A.framework/Versions/A/Frameworks/B.framework/Versions/C/B", 55, 56}, // innermost framework
+		{"Dylib", "Foo.framework/Versions/Current/Foo", 0, 0},                           // not a version
+		{"Dylib", "Foo.framework/Versions/A", 0, 0},                                     // the directory itself
+		{"Dylib", "Foo.framework/Versions//Foo", 0, 0},
+		{"Dylib", "/.framework/Versions/A/Foo", 0, 0}, // empty framework name
+		{"Dylib", "libfoo.dylib", 0, 0},               // no version
+		{"Dylib", "libbar.4.5.dylib", 0, 0},           // dotted version (see Embedded)
+		{"Dylib", "libfoo-1.dylib", 0, 0},
+		{"Dylib", "lib/.1.dylib", 0, 0}, // empty name
+		{"Dylib", ".1.dylib", 0, 0},
+		{"Dylib", "libfoo.1.so", 0, 0}, // not a dylib
+		{"Gem", "rails-7.1.3.gem", 5, 11},
+		{"Gem", "nokogiri-1.16.0-x86_64-linux.gem", 8, 15},
+		{"Gem", "nokogiri-1.16.0-x86_64-linux-musl.gem", 8, 15},
+		{"Gem", "cache/nokogiri-1.16.0-universal-darwin-22.gem", 14, 21},
+		{"Gem", "rack-3.1.0.beta1.gem", 4, 16},
+		{"Gem", "aws-sdk-s3-1.142.0.gem", 10, 18},
+		{"Gem", "json-2.7.1-java.gem", 4, 10},
+		{"Gem", "rails.gem", 0, 0},           // no version
+		{"Gem", "foo-bar.gem", 0, 0},         // no version
+		{"Gem", "foo-1.0.-x.gem", 0, 0},      // version ends with a dot
+		{"Gem", "foo-1.0-22.gem", 0, 0},      // platform must start with a letter
+		{"Gem", "foo-1.0_x.gem", 0, 0},       // invalid version
+		{"Gem", "gems/-1.0.gem", 0, 0},       // empty name
+		{"Gem", "rails-7.1.3.gemspec", 0, 0}, // not a gem
+		{"Crate", "serde-1.0.197.crate", 5, 13},
+		{"Crate", "h2-0.4.2.crate", 2, 8},
+		{"Crate", "registry/cache/base64-0.21.7.crate", 21, 28},
+		{"Crate", "foo-3d-1.0.0-alpha.1.crate", 6, 20},
+		{"Crate", "libserde-abcdef0123456789.rlib", 8, 25},
+		{"Crate", "target/release/deps/libsha2-0a1b2c3d.rmeta", 27, 36},
+		{"Crate", "serde.crate", 0, 0},                     // no version
+		{"Crate", "foo-3d.crate", 0, 0},                    // digits in the name
+		{"Crate", "serde-1.crate", 0, 0},                   // not a SemVer version
+		{"Crate", "libserde.rlib", 0, 0},                   // no hash
+		{"Crate", "libserde-abc123.rlib", 0, 0},            // hash too short
+		{"Crate", "libserde-abcdef01234567890.rlib", 0, 0}, // hash too long
+		{"Crate", "libserde-ABCDEF0123456789.rlib", 0, 0},  // not lowercase hex
+		{"Crate", "deps/-abcdef0123456789.rlib", 0, 0},     // empty name
+		{"Crate", "libserde-abcdef0123456789.so", 0, 0},    // not a crate
+		{"Nupkg", "newtonsoft.json.13.0.3.nupkg", 15, 22},
+		{"Nupkg", "xunit.2.6.6.nupkg", 5, 11},
+		{"Nupkg", "system.text.json.8.0.0.snupkg", 16, 22},
+		{"Nupkg", "microsoft.netcore.app.ref.8.0.0-preview.7.23375.6.nupkg", 25, 49},
+		{"Nupkg", "packages/foo.2.bar.1.0.nupkg", 18, 22},      // numeric id segment
+		{"Nupkg", "castle.core.5.1.1.4.nupkg", 11, 19},         // four parts
+		{"Nupkg", "newtonsoft.json.nupkg", 0, 0},               // no version
+		{"Nupkg", "sqlite3.nupkg", 0, 0},                       // digits in the id
+		{"Nupkg", "foo.1.nupkg", 0, 0},                         // one part
+		{"Nupkg", "foo.1.2.3.4.5.nupkg", 5, 13},                // at most four parts, so the id is "foo.1"
+		{"Nupkg", "foo.1..2.nupkg", 0, 0},                      // empty part
+		{"Nupkg", "foo.1.2-.nupkg", 0, 0},                      // empty pre-release label
+		{"Nupkg", "foo.1.2-beta_1.nupkg", 0, 0},                // invalid pre-release label
+		{"Nupkg", "packages/.1.0.nupkg", 0, 0},                 // empty id
+		{"Nupkg", "newtonsoft.json.13.0.3.nupkg.sha512", 0, 0}, // not a package
+	}
+
+	for _, tt := range tests {
+		gotI, gotJ := matchers[tt.matcher]([]byte(tt.input))
+		if gotI != tt.wantI || gotJ != tt.wantJ {
+			t.Errorf("%s(%q) = (%d, %d), want (%d, %d)",
+				tt.matcher, tt.input, gotI, gotJ, tt.wantI, tt.wantJ)
+		}
+	}
+}
+
+// TestDiff_Matchers diffs each pair on its own: Updated and Unchanged pairs must match each
+// other, while Removed pairs must not share an identity.
+func TestDiff_Matchers(t *testing.T) {
+	tests := []struct {
+		old, cur string
+		want     Status
+	}{
+		// Rpm
+		{"bash-5.2.15-1.fc39.x86_64.rpm", "bash-5.2.16-1.fc39.x86_64.rpm", Updated},
+		{"dash-0.5.12-1.fc39.x86_64.rpm", "dash-0.5.12-2.fc39.x86_64.rpm", Updated},
+		{"libfoo2-1.0-1.x86_64.rpm", "libfoo2-1.1-1.x86_64.rpm", Updated},
+
+		// Deb
+		{"nginx_1.24.0-1_amd64.deb", "nginx_1.26.2-2_amd64.deb", Updated},
+		{"libc6_2.36-9_amd64.deb", "libc6_1:2.37-1_amd64.deb", Updated},
+		{"my_script_v2_final", "my_script_v3_final", Removed},
+
+		// Npm
+		{"lodash-4.17.20.tgz", "lodash-4.17.21.tgz", Updated},
+		{"@babel/core-7.23.9.tgz", "@babel/core-7.24.0.tgz", Updated},
+		{"babel-plugin-foo-1.2.3.tgz", "babel-plugin-foo-2.0.0-rc.1.tgz", Updated},
+		{"babel-plugin-bar-1.2.3.tgz", "babel-plugin-baz-1.2.3.tgz", Removed},
+
+		// Jar
+		{"guava-32.1.3-jre.jar", "guava-33.0.0-jre.jar", Updated},
+		{"commons-lang3-3.13.0.jar", "commons-lang3-3.14.0.jar", Updated},
+		{"foo-1.2.3.jar", "foo-1.2.4-SNAPSHOT.jar", Updated},
+		{"foo-1.2.3-sources.jar", "foo-1.2.4-SNAPSHOT-sources.jar", Updated},
+
+		// GoModule
+		{"github.com/foo/bar/@v/v1.2.3.zip", "github.com/foo/bar/@v/v1.3.0.zip", Updated},
+		{"github.com/foo/bar/@v/v1.2.3.mod", "github.com/foo/bar/@v/v1.3.0.mod", Updated},
+		{
+			"github.com/foo/bar/@v/v0.0.0-20230101000000-abcdef123456.info",
+			"github.com/foo/bar/@v/v0.0.0-20240101000000-123456abcdef.info",
+			Updated,
+		},
+		{"github.com/foo/baz/@v/v2.0.0+incompatible.zip", "github.com/foo/baz/@v/v3.0.0+incompatible.zip", Removed},
+
+		// Kmod
+		{"nvidia-535.154.05.ko", "nvidia-550.54.14.ko", Updated},
+		{"zfs-2.2.2.ko.zst", "zfs-2.2.3.ko.xz", Updated},
+		{"i915.ko.xz", "i915.ko", Removed},
+
+		// DateStamp
+		{"myapp.nightly.20250114.tar.gz", "myapp.nightly.20250115.tar.gz", Updated},
+		{"snapshot.20250114-120000_disk.img", "snapshot.20250115-120000_disk.img", Updated},
+		{"blob.20250114abcd.bin", "blob.20250115abcd.bin", Removed},
+
+		// ContentHash
+		{"main.4f3a9c1b.js", "main.0011aabb.js", Updated},
+		{"main.4f3a9c1b.js.map", "main.0011aabb.js.map", Updated},
+		{"vendor.ab12cd34.css", "vendor.cd34ab12.css", Updated},
+		{"app.9f8e7d6c.chunk.js", "app.6c7d8e9f.chunk.js", Updated},
+
+		// Suffix
+		{"foo-1.2.3-alpha.1+build.5", "foo-1.2.3", Updated},
+		{"app-1.2.3-beta1", "app-1.2.3-rc2", Updated},
+		{"tool-2.0.0+build.7-r0", "tool-2.0.1-r1", Updated},
+		{"pkg-2:1.2.3-r0", "pkg-2:1.2.4-r0", Updated},
+		{"pkg2:1.2.3", "pkg2:1.2.4", Removed},
+
+		// Dll
+		{"api-ms-win-core-file-l1-2-0.dll", "api-ms-win-core-file-l1-2-1.dll", Updated},
+		{"vcruntime140_1.dll", "vcruntime140_2.dll", Updated},
+		{"libcrypto-1_1-x64.dll", "libcrypto-3-x64.dll", Updated},
+		{"libcrypto-3-x86.dll", "libcrypto-3-arm64.dll", Removed},
+		{"vcruntime140.dll", "vcruntime150_1.dll", Removed},
+
+		// Dylib
+		{"lib/libfoo.1.dylib", "lib/libfoo.2.dylib", Updated},
+		{"Foo.framework/Versions/A/Foo", "Foo.framework/Versions/B/Foo", Updated},
+		{"Foo.framework/Versions/Current", "Foo.framework/Versions/Current", Unchanged},
+		{"libbar.4.5.6.dylib", "libbar.4.5.7.dylib", Updated},
+
+		// Gem
+		{"rails-7.1.3.gem", "rails-7.2.0.gem", Updated},
+		{"nokogiri-1.16.0-x86_64-linux.gem", "nokogiri-1.17.0-x86_64-linux.gem", Updated},
+		{"nokogiri-1.16.0-arm64-darwin.gem", "nokogiri-1.17.0-arm64-darwin.gem", Updated},
+		{"nokogiri-1.16.0-x86_64-linux.gem", "nokogiri-1.17.0-arm64-darwin.gem", Removed}, // different platforms
+		{"rack-3.0.9.gem", "rack-3.1.0.beta1.gem", Updated},
+
+		// Crate
+		{"serde-1.0.197.crate", "serde-1.0.198.crate", Updated},
+		{"h2-0.4.2.crate", "h2-0.4.3.crate", Updated},
+		{"deps/libserde-abcdef0123456789.rlib", "deps/libserde-9876543210fedcba.rlib", Updated},
+		{"deps/libsha2-0a1b2c3d4e5f6071.rmeta", "deps/libsha2-1f2e3d4c5b6a7980.rmeta", Updated},
+
+		// Nupkg
+		{"newtonsoft.json.13.0.3.nupkg", "newtonsoft.json.13.0.4.nupkg", Updated},
+		{"xunit.2.6.6.nupkg", "xunit.2.7.0.nupkg", Updated},
+		{"microsoft.netcore.app.ref.8.0.0-preview.7.23375.6.nupkg", "microsoft.netcore.app.ref.8.0.1.nupkg", Updated},
+		{"newtonsoft.json.13.0.3.nupkg", "newtonsoft.json.bson.1.0.2.nupkg", Removed}, // different ids
+	}
+
+	for _, tt := range tests {
+		r := Diff([]string{tt.old}, []string{tt.cur})
+
+		want := newEntry(0, 0, tt.want)
+		if tt.want == Removed {
+			want = newEntry(0, null, Removed)
+		}
+		if r.E[0] != want {
+			t.Errorf("Diff(%q, %q) = %+v, want %+v", tt.old, tt.cur, r.E[0], want)
+		}
+	}
+}
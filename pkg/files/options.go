@@ -0,0 +1,537 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"runtime"
+	"strings"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// ErrTooManyEntries is returned by DiffWithOptions when a result would exceed Options.MaxEntries,
+// and by the functions returning an error when the new file list holds more than 2^29-1 files.
+var ErrTooManyEntries = errors.New("too many entries")
+
+// ErrContentLength is returned by DiffWithOptions when Options.OldContent or Options.CurContent
+// is set but does not hold one hash per file of the corresponding list.
+var ErrContentLength = errors.New("content hashes do not match the file list")
+
+// Options configures DiffWithOptions.
+// The zero value produces the same result as Diff.
+type Options struct {
+	// StripTriplets removes multiarch triplet directories (e.g., "x86_64-linux-gnu")
+	// from paths before hashing so that cross-architecture trees reconcile.
+	// Paths which only differ by triplet are then considered Unchanged.
+	StripTriplets bool
+
+	// StripLeadingSlash removes leading "/" characters from paths before hashing
+	// so that absolute and relative forms of the same path reconcile.
+	// Since the exact comparison also uses the stripped path, "/usr/bin/foo" and
+	// "usr/bin/foo" are considered Unchanged.
+	StripLeadingSlash bool
+
+	// Ignore, when set, reports paths to leave out of the diff entirely, and IgnorePatterns
+	// lists path.Match patterns for the same purpose. Patterns without a "/" are matched
+	// against every segment of a path, so "*.pyc" ignores compiled files in any directory
+	// and "__pycache__" ignores everything below such directories; other patterns are
+	// matched against the whole path. Both sides are filtered the same way, using the
+	// original paths (before any other rewriting), and ignored files produce no entries and
+	// are not counted. Entry indices still refer to the original slices, as do OldContent,
+	// CurContent, and TrustedPairs.
+	Ignore         func(path string) bool
+	IgnorePatterns []string
+
+	// NormalizePaths rewrites paths to a canonical form before hashing so that differently
+	// spelled references to the same file reconcile: leading "./" and "/" prefixes are removed
+	// and runs of "/" are collapsed, so "./usr/bin/ls", "/usr/bin/ls", and "usr//bin/ls" are
+	// all considered Unchanged against "usr/bin/ls". Only separators are rewritten, so the
+	// identity of the file name itself is detected as usual.
+	NormalizePaths bool
+
+	// BackslashSeparators treats "\" as a path separator, as in manifests from Windows, by
+	// rewriting it to "/" before hashing (and before NormalizePaths is applied). Both the
+	// exact and identity comparisons use the rewritten path, so "lib\x86\libfoo.so.1" is
+	// considered Unchanged against "lib/x86/libfoo.so.1" and Updated to "lib/x86/libfoo.so.2".
+	// This is opt-in since "\" is an ordinary character in Unix file names.
+	BackslashSeparators bool
+
+	// OldPrefix and NewPrefix are removed from the start of old and new paths respectively
+	// before any other rewriting, so that trees rooted at different directories (e.g.,
+	// "rootfs-a/" and "rootfs-b/") reconcile by their relative paths. Paths without the
+	// prefix are compared as-is rather than dropped.
+	OldPrefix string
+	NewPrefix string
+
+	// OldContent and CurContent optionally hold content hashes aligned with the
+	// old and cur file lists. When both are set, files with identical names but
+	// different content hashes are reported as ContentChanged instead of Unchanged.
+	// Files whose names differ by version are still reported as Updated.
+	// DiffWithOptions fails with ErrContentLength if a slice that is set does not have
+	// the same length as its file list.
+	OldContent []uint64
+	CurContent []uint64
+
+	// DetectRenames pairs files which would otherwise be reported as Removed and Added when
+	// they have the same content hash and the same extension (e.g., "oldname.conf" and
+	// "newname.conf"), reporting them as Renamed. It requires OldContent and CurContent.
+	// Renames are detected after all exact and identity matches, in old file order; when
+	// several new files qualify, the one with the lowest index is chosen. Requiring the
+	// same extension avoids pairing unrelated files that share common content, such as
+	// empty files.
+	DetectRenames bool
+
+	// IdentityFirst checks for identity matches before exact matches.
+	// By default an old file is paired with a new file of the same name (Unchanged)
+	// whenever one exists. With IdentityFirst, an old file is instead paired with the
+	// first new file sharing its identity, so "libfoo.so.1" is reported as Updated to
+	// "libfoo.so.2" even when "libfoo.so.1" also exists in the new list (which is then Added).
+	IdentityFirst bool
+
+	// IdentityHashes populates Result.IdentityHashes so that callers can pair
+	// Removed and Added entries by identity without recomputing hashes.
+	IdentityHashes bool
+
+	// OpamStyle treats opam-style "name.VERSION" names (e.g., "dune.3.14.0") as versioned
+	// so that "dune.3.14.0" and "dune.3.15.0" reconcile as Updated. This is opt-in since
+	// the pattern is ambiguous with ordinary dotted file names.
+	OpamStyle bool
+
+	// MaxEntries limits the number of entries in a result to bound memory use for
+	// untrusted input. When the projected entry count exceeds the limit, DiffWithOptions
+	// fails with ErrTooManyEntries instead of allocating the result; no partial result
+	// or counts are returned. Zero means no limit.
+	MaxEntries int
+
+	// CollapseVersionedDirs reports a versioned directory whose whole subtree was renamed
+	// (e.g., every file under "app-1.0/" now lives under "app-2.0/") as a single Updated
+	// entry instead of one Removed and one Added entry per file. The entry pairs the first
+	// old file of the directory with its new counterpart and Result.Dirs describes the
+	// directories.
+	//
+	// Two directories are collapsed when their paths are equal once the version of the
+	// outermost versioned directory is removed ("opt/app-1.0" and "opt/app-2.0" both become
+	// "opt/app"), each is the only such directory in its file list, every file under the old
+	// directory was Removed, every file under the new directory was Added, and both contain
+	// exactly the same relative paths.
+	CollapseVersionedDirs bool
+
+	// Matchers are custom identity matchers tried in order before the built-in matchers.
+	// They are used for both hashing and verifying identity matches so Diff stays consistent.
+	Matchers []Matcher
+
+	// MatchersLast tries Matchers after the built-in matchers instead of before, so that
+	// custom matchers only apply to names no built-in matcher recognizes.
+	MatchersLast bool
+
+	// ConcatSpans hashes two-span identities (e.g., Script and Embedded names) as the
+	// concatenation of both spans rather than XOR-ing the hash of each span. This lowers
+	// the number of identity hash collisions, which are otherwise resolved by comparing
+	// names, at a small cost in hashing speed. The result is the same either way.
+	ConcatSpans bool
+
+	// CaseInsensitive folds paths to lower case before hashing, as on a case-insensitive
+	// filesystem such as APFS or NTFS. Both the exact and identity comparisons use the
+	// folded path, so "Foo.txt" and "foo.txt" are considered Unchanged and "LibFoo.so.1"
+	// and "libfoo.so.2" are considered Updated.
+	// Only ASCII letters are folded: "Ä.txt" and "ä.txt" still differ, as do names which
+	// the filesystem would treat as equal after Unicode normalization.
+	CaseInsensitive bool
+
+	// TieBreak chooses which of several new files sharing an identity an old file is
+	// matched against (e.g., "libfoo.so.1" against two copies of "libfoo.so.2").
+	// The default, LowestIndex, chooses the first one in the new file list.
+	TieBreak TieBreak
+
+	// AssetExtensions overrides the extensions of versioned asset packs such as
+	// "pack_v1.2.3.zip", "textures-2.0.pak", and "sounds.v3.bank" that reconcile by name.
+	// Nil uses the defaults (".zip", ".pak", and ".bank"); an empty non-nil slice
+	// disables asset pack matching.
+	AssetExtensions []string
+
+	// EmbeddedExtensions overrides the library extensions of names with an embedded version
+	// such as "foo.1.2.3.so" that reconcile by name. Nil uses the defaults (".so", ".dylib",
+	// ".dll", and ".a"); an empty non-nil slice disables embedded version matching.
+	// Other extensions are excluded by default so that documents like "rfc.1.2.3.txt" and
+	// "rfc.4.5.6.txt" are not mistaken for versions of one file.
+	EmbeddedExtensions []string
+
+	// OpaqueDigests treats paths with a segment starting with "sha256:" or "sha512:"
+	// (e.g., "blobs/sha256:4f3a9c1b...") as exact-only, so content-addressed blobs are
+	// Unchanged or Removed and Added but never Updated, even when a digest happens to look
+	// like a versioned name. Digest-pinned image references such as "ubuntu@sha256:..."
+	// still reconcile by repository. It takes precedence over Matchers.
+	OpaqueDigests bool
+
+	// TrustedPairs lists (old, new) file index pairs already known to be identical, such as
+	// from a prior content hash comparison. Each trusted pair is reported as Unchanged (or as
+	// ContentChanged when OldContent and CurContent are set and differ) without looking up or
+	// comparing the names, so an incorrect pair is silently misreported; only
+	// pass pairs from a trusted source. Pairs with out-of-range indices, and pairs whose old or
+	// new file appears in an earlier pair, are ignored.
+	TrustedPairs []IndexPair
+
+	// IgnoreExtensions lists extensions (including the leading ".") removed from the end of
+	// paths before hashing, such as compression extensions that only reflect how an artifact
+	// was packed. Extensions are removed repeatedly, so with ".gz", ".xz", and ".zst" the paths
+	// "foo-1.2.3.tar.gz" and "foo-1.2.3.tar.zst" are considered Unchanged and
+	// "foo-1.3.0.tar.xz" is considered Updated. A file name is never stripped to nothing.
+	IgnoreExtensions []string
+
+	// WorkStealing reconciles old files in small blocks handed out to workers on demand
+	// rather than one equal range per worker. This evens out the work when some ranges are
+	// much more expensive than others (e.g., long names with many identity matches) at a
+	// small cost in coordination. The result is the same either way.
+	WorkStealing bool
+
+	// ExactFilter builds a Bloom filter over the names of new files so that old files which
+	// certainly have no new file of the same name skip the exact match lookup. This can help
+	// when most names changed (e.g., most files were Updated, Removed, or Added) but costs
+	// about one byte per new file and the time to build the filter, and the lookup it saves
+	// is already cheap, so measure before enabling it. The filter never hides an exact
+	// match, so the result is the same either way.
+	ExactFilter bool
+
+	// Workers sets the number of goroutines used for hashing and reconciliation.
+	// Zero (or a negative value) uses runtime.GOMAXPROCS(0) like Diff; see DiffN.
+	// The result is the same either way.
+	Workers int
+
+	// Seed sets the seed used to hash file names. The zero value uses a seed chosen once
+	// per process. Entries don't depend on the seed, since they are ordered by file index
+	// and every file's identity is verified by name, but Result.IdentityHashes do: diffs
+	// with the same seed produce identical identity hashes. A maphash.Seed cannot be
+	// persisted, so hashes are only comparable within a single process.
+	Seed maphash.Seed
+
+	// Progress, when set, is called periodically while old files are reconciled with the
+	// number reconciled so far and the total number of old files. Calls are batched (about
+	// every 65536 files) and the last call reports done == total unless the diff was
+	// cancelled. Progress may be called concurrently from multiple workers, so it must be
+	// safe for concurrent use, and successive calls may arrive out of order; it should
+	// return quickly since workers wait for it.
+	Progress func(done, total int)
+
+	// Stats populates Result.Stats with counts of the hash lookups that were verified by
+	// comparing names, including collisions. Counting costs an atomic increment per
+	// comparison and nothing when Stats is unset. The result is the same either way.
+	Stats bool
+}
+
+// IndexPair is a pair of old and new file indices.
+type IndexPair struct {
+	Old uint32
+	New uint32
+}
+
+// TieBreak selects between new files with the same identity.
+type TieBreak uint8
+
+const (
+	LowestIndex  TieBreak = iota // Prefer the new file with the lowest index
+	HighestIndex                 // Prefer the new file with the highest index
+)
+
+// prefer reports whether new file index i should replace index prev.
+func (t TieBreak) prefer(i, prev uint32) bool {
+	if t == HighestIndex {
+		return i > prev
+	}
+
+	return i < prev
+}
+
+// Matcher detects the identity of a file name as up to two byte ranges:
+// identity = name[:j] + name[s:e]. Single-span matchers return s == e (typically 0, 0),
+// while two-span matchers exclude an ephemeral middle portion such as a version or build ID.
+// Returning j == 0 reports no match so that the next matcher is tried.
+// The name must not be modified or retained.
+type Matcher func(name []byte) (j, s, e int)
+
+// DiffWithOptions compares two file lists using the given options.
+// Entry indices always refer to the original old and cur slices.
+// An error is only returned when Options.MaxEntries is exceeded, Options.IgnorePatterns
+// holds a malformed pattern, or the content hashes do not match the file lists.
+func DiffWithOptions(old, cur []string, opts Options) (*Result, error) {
+	if err := opts.checkContent(len(old), len(cur)); err != nil {
+		return nil, err
+	}
+
+	var oldIdx, curIdx []uint32
+	if opts.ignoring() {
+		if err := opts.checkPatterns(); err != nil {
+			return nil, err
+		}
+		if len(cur) > maxNewFiles {
+			return nil, fmt.Errorf("%w: %d new files exceed the limit of %d", ErrTooManyEntries, len(cur), maxNewFiles)
+		}
+		old, cur, oldIdx, curIdx = opts.filterInputs(old, cur)
+	}
+
+	old, cur = opts.normalize(old, opts.OldPrefix), opts.normalize(cur, opts.NewPrefix)
+
+	r, err := diff(old, cur, opts.workers(), &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Diffs with an empty side skip the lookups entirely.
+	if opts.Stats && r.Stats == nil {
+		r.Stats = &Stats{}
+	}
+
+	if opts.CollapseVersionedDirs {
+		collapseVersionedDirs(r, old, cur)
+	}
+
+	if opts.ignoring() {
+		restoreIndices(r, oldIdx, curIdx)
+	}
+
+	return r, nil
+}
+
+// normalize applies any path rewriting options to files, first removing prefix.
+// The original slice is returned as-is when no rewriting is required.
+func (o *Options) normalize(files []string, prefix string) []string {
+	if !o.rewrites() && prefix == "" {
+		return files
+	}
+
+	out := make([]string, len(files))
+	for i, f := range files {
+		f = strings.TrimPrefix(f, prefix)
+		if o.BackslashSeparators {
+			f = strings.ReplaceAll(f, "\\", "/")
+		}
+		if o.NormalizePaths {
+			f = normalizePath(f)
+		}
+		if o.StripLeadingSlash {
+			f = strings.TrimLeft(f, "/")
+		}
+		if o.StripTriplets {
+			f = stripTriplets(f)
+		}
+		if o.CaseInsensitive {
+			f = lowerASCII(f)
+		}
+		if len(o.IgnoreExtensions) > 0 {
+			f = stripExtensions(f, o.IgnoreExtensions)
+		}
+		out[i] = f
+	}
+
+	return out
+}
+
+// config returns the identity configuration for the options,
+// or nil if only the built-in matchers are needed.
+func (o *Options) config() *identity.Config {
+	if !o.OpamStyle && !o.ConcatSpans && !o.OpaqueDigests && len(o.Matchers) == 0 && o.AssetExtensions == nil && o.EmbeddedExtensions == nil {
+		return nil
+	}
+
+	cfg := &identity.Config{
+		Opam:               o.OpamStyle,
+		ConcatSpans:        o.ConcatSpans,
+		AssetExtensions:    o.AssetExtensions,
+		EmbeddedExtensions: o.EmbeddedExtensions,
+		MatchersLast:       o.MatchersLast,
+		OpaqueDigests:      o.OpaqueDigests,
+	}
+	for _, m := range o.Matchers {
+		cfg.Matchers = append(cfg.Matchers, identity.Matcher(m))
+	}
+
+	return cfg
+}
+
+// workers returns the number of workers to use.
+func (o *Options) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+
+	return max(1, runtime.GOMAXPROCS(0))
+}
+
+// seed returns the seed to hash file names with.
+func (o *Options) seed() maphash.Seed {
+	if o.Seed == (maphash.Seed{}) {
+		return seed
+	}
+
+	return o.Seed
+}
+
+// checkEntries returns ErrTooManyEntries if n entries exceed the configured limit.
+func (o *Options) checkEntries(n int) error {
+	if o.MaxEntries > 0 && n > o.MaxEntries {
+		return fmt.Errorf("%w: %d exceeds the limit of %d", ErrTooManyEntries, n, o.MaxEntries)
+	}
+
+	return nil
+}
+
+// trusted returns the trusted new file index for each old file (or null),
+// or nil if there are no trusted pairs. Each old and new file is only used by
+// the first pair it appears in.
+func (o *Options) trusted(oldFiles, newFiles int) []uint32 {
+	if len(o.TrustedPairs) == 0 {
+		return nil
+	}
+
+	trusted := make([]uint32, oldFiles)
+	for i := range trusted {
+		trusted[i] = null
+	}
+
+	claimed := make(map[uint32]bool, len(o.TrustedPairs))
+	for _, p := range o.TrustedPairs {
+		if int(p.Old) < oldFiles && int(p.New) < newFiles && trusted[p.Old] == null && !claimed[p.New] {
+			trusted[p.Old] = p.New
+			claimed[p.New] = true
+		}
+	}
+
+	return trusted
+}
+
+// checkContent returns ErrContentLength if a set content hash slice does not have
+// one hash per file.
+func (o *Options) checkContent(oldFiles, newFiles int) error {
+	if o.OldContent != nil && len(o.OldContent) != oldFiles {
+		return fmt.Errorf("%w: %d old content hashes for %d files", ErrContentLength, len(o.OldContent), oldFiles)
+	}
+	if o.CurContent != nil && len(o.CurContent) != newFiles {
+		return fmt.Errorf("%w: %d new content hashes for %d files", ErrContentLength, len(o.CurContent), newFiles)
+	}
+
+	return nil
+}
+
+// hasContent reports whether content hashes are available for both file lists.
+func (o *Options) hasContent(oldFiles, newFiles int) bool {
+	return o.OldContent != nil && o.CurContent != nil &&
+		len(o.OldContent) == oldFiles && len(o.CurContent) == newFiles
+}
+
+// rewrites reports whether any option requires the input paths to be rewritten.
+func (o *Options) rewrites() bool {
+	return o.StripTriplets || o.StripLeadingSlash || o.NormalizePaths || o.BackslashSeparators || o.CaseInsensitive || len(o.IgnoreExtensions) > 0
+}
+
+// normalizePath removes leading "./" and "/" prefixes from p and collapses runs of "/".
+// A path consisting only of such prefixes is returned as-is.
+func normalizePath(p string) string {
+	q := p
+	for {
+		if rest, ok := strings.CutPrefix(q, "./"); ok {
+			q = rest
+		} else if rest, ok := strings.CutPrefix(q, "/"); ok {
+			q = rest
+		} else {
+			break
+		}
+	}
+	if q == "" {
+		return p
+	}
+	if !strings.Contains(q, "//") {
+		return q
+	}
+
+	b := make([]byte, 0, len(q))
+	for i := range len(q) {
+		if q[i] != '/' || i == 0 || q[i-1] != '/' {
+			b = append(b, q[i])
+		}
+	}
+
+	return string(b)
+}
+
+// lowerASCII returns p with ASCII upper case letters folded to lower case.
+// Other bytes, including those of multi-byte UTF-8 sequences, are left as-is,
+// and p itself is returned when it has no upper case letters.
+func lowerASCII(p string) string {
+	i := 0
+	for i < len(p) && p[i]-'A' >= 26 {
+		i++
+	}
+	if i == len(p) {
+		return p
+	}
+
+	b := []byte(p)
+	for ; i < len(b); i++ {
+		if b[i]-'A' < 26 {
+			b[i] += 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// stripExtensions repeatedly removes any of the given extensions from the end of p
+// as long as the file name remains non-empty.
+func stripExtensions(p string, exts []string) string {
+	for stripped := true; stripped; {
+		stripped = false
+		for _, ext := range exts {
+			rest, ok := strings.CutSuffix(p, ext)
+			if ext == "" || !ok || rest == "" || strings.HasSuffix(rest, "/") {
+				continue
+			}
+			p, stripped = rest, true
+		}
+	}
+
+	return p
+}
+
+// stripTriplets removes multiarch triplet directory segments from a path.
+// The final path segment (the file name) is never removed.
+func stripTriplets(p string) string {
+	if !strings.Contains(p, "-linux-") {
+		return p
+	}
+
+	var b strings.Builder
+	rest := p
+	for {
+		seg, tail, ok := strings.Cut(rest, "/")
+		if !ok {
+			b.WriteString(rest)
+			break
+		}
+
+		if !isTriplet(seg) {
+			b.WriteString(seg)
+			b.WriteByte('/')
+		}
+		rest = tail
+	}
+
+	return b.String()
+}
+
+// isTriplet reports whether seg looks like a multiarch tuple such as
+// "x86_64-linux-gnu", "arm-linux-gnueabihf", or "aarch64-linux-musl".
+func isTriplet(seg string) bool {
+	cpu, abi, ok := strings.Cut(seg, "-linux-")
+	if !ok || cpu == "" {
+		return false
+	}
+
+	for i := range len(cpu) {
+		c := cpu[i]
+		if c-'0' >= 10 && (c|32)-'a' >= 26 && c != '_' {
+			return false
+		}
+	}
+
+	return strings.HasPrefix(abi, "gnu") || strings.HasPrefix(abi, "musl")
+}
@@ -0,0 +1,907 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// mustDiff calls DiffWithOptions and fails the test on error.
+func mustDiff(t *testing.T, old, cur []string, opts Options) *Result {
+	t.Helper()
+
+	r, err := DiffWithOptions(old, cur, opts)
+	if err != nil {
+		t.Fatalf("DiffWithOptions() error = %v", err)
+	}
+
+	return r
+}
+
+func TestStripTriplets(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"usr/lib/x86_64-linux-gnu/libc.so.6", "usr/lib/libc.so.6"},
+		{"usr/lib/aarch64-linux-gnu/libc.so.6", "usr/lib/libc.so.6"},
+		{"lib/arm-linux-gnueabihf/libm.so.6", "lib/libm.so.6"},
+		{"usr/lib/x86_64-linux-musl/libz.so.1", "usr/lib/libz.so.1"},
+		{"x86_64-linux-gnu/libc.so.6", "libc.so.6"},
+		{"usr/include/x86_64-linux-gnu", "usr/include/x86_64-linux-gnu"}, // file name is kept
+		{"usr/lib/my-linux-tool/foo", "usr/lib/my-linux-tool/foo"},       // not a triplet
+		{"usr/bin/ls", "usr/bin/ls"},
+	}
+
+	for _, tt := range tests {
+		if got := stripTriplets(tt.input); got != tt.want {
+			t.Errorf("stripTriplets(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiffWithOptions_StripTriplets(t *testing.T) {
+	old := []string{"usr/lib/x86_64-linux-gnu/libc.so.6", "usr/lib/x86_64-linux-gnu/libssl.so.3.0.1"}
+	cur := []string{"usr/lib/aarch64-linux-gnu/libc.so.6", "usr/lib/aarch64-linux-gnu/libssl.so.3.0.2"}
+
+	r := Diff(old, cur)
+	if r.Count(Removed) != 2 || r.Count(Added) != 2 {
+		t.Errorf("Diff without options: removed=%d added=%d, want 2 and 2", r.Count(Removed), r.Count(Added))
+	}
+
+	r = mustDiff(t, old, cur, Options{StripTriplets: true})
+	if r.Count(Unchanged) != 1 || r.Count(Updated) != 1 {
+		t.Errorf("DiffWithOptions: unchanged=%d updated=%d, want 1 and 1", r.Count(Unchanged), r.Count(Updated))
+	}
+}
+
+func TestDiffWithOptions_Zero(t *testing.T) {
+	old := []string{"lib.so.1", "bin/foo", "doc.md", "old.txt"}
+	cur := []string{"lib.so.2", "bin/foo", "doc.md", "new.txt"}
+
+	want := Diff(old, cur)
+	got := mustDiff(t, old, cur, Options{})
+
+	for _, s := range []Status{Unchanged, Updated, Removed, Added} {
+		if got.Count(s) != want.Count(s) {
+			t.Errorf("Count(%d) = %d, want %d", s, got.Count(s), want.Count(s))
+		}
+	}
+	if !slices.Equal(got.E, want.E) {
+		t.Errorf("entries = %v, want %v", got.E, want.E)
+	}
+}
+
+func TestDiffWithOptions_Workers(t *testing.T) {
+	old, cur := genMixed(5_000)
+	want := Diff(old, cur)
+
+	for _, workers := range []int{-1, 0, 1, 3, 16} {
+		got := mustDiff(t, old, cur, Options{Workers: workers})
+		if !slices.Equal(got.E, want.E) {
+			t.Errorf("workers=%d: Workers changed the entries", workers)
+		}
+	}
+
+	if got := (&Options{Workers: 3}).workers(); got != 3 {
+		t.Errorf("workers() = %d, want 3", got)
+	}
+	if got, want := (&Options{}).workers(), max(1, runtime.GOMAXPROCS(0)); got != want {
+		t.Errorf("default workers() = %d, want %d", got, want)
+	}
+}
+
+func TestDiffWithOptions_StripLeadingSlash(t *testing.T) {
+	old := []string{"/usr/bin/foo", "/usr/lib/libfoo.so.1", "//etc/passwd"}
+	cur := []string{"usr/bin/foo", "usr/lib/libfoo.so.2", "etc/passwd"}
+
+	r := Diff(old, cur)
+	if r.Count(Removed) != 3 || r.Count(Added) != 3 {
+		t.Errorf("Diff without options: removed=%d added=%d, want 3 and 3", r.Count(Removed), r.Count(Added))
+	}
+
+	r = mustDiff(t, old, cur, Options{StripLeadingSlash: true})
+	if r.Count(Unchanged) != 2 || r.Count(Updated) != 1 {
+		t.Errorf("DiffWithOptions: unchanged=%d updated=%d, want 2 and 1", r.Count(Unchanged), r.Count(Updated))
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"usr/bin/ls":      "usr/bin/ls",
+		"./usr/bin/ls":    "usr/bin/ls",
+		"/usr/bin/ls":     "usr/bin/ls",
+		"//usr//bin///ls": "usr/bin/ls",
+		"././/./usr/bin/": "usr/bin/",
+		".hidden/./file":  ".hidden/./file",
+		"../usr/bin/ls":   "../usr/bin/ls",
+		"./":              "./",
+		"/":               "/",
+		"":                "",
+		"usr/lib//x.so.1": "usr/lib/x.so.1",
+	}
+
+	for in, want := range tests {
+		if got := normalizePath(in); got != want {
+			t.Errorf("normalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDiffWithOptions_NormalizePaths(t *testing.T) {
+	old := []string{"./usr/bin/ls", "/usr/bin/cat", "usr/bin/cp", "usr//lib/libfoo.so.1", "./opt/app-1.0.0.jar"}
+	cur := []string{"usr/bin/ls", "usr/bin/cat", "./usr/bin/cp", "/usr/lib/libfoo.so.2", "opt/app-1.1.0.jar"}
+
+	r := Diff(old, cur)
+	if r.Count(Unchanged) != 0 {
+		t.Fatalf("Diff: unchanged=%d, want 0", r.Count(Unchanged))
+	}
+
+	r = mustDiff(t, old, cur, Options{NormalizePaths: true})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Unchanged),
+		newEntry(2, 2, Unchanged),
+		newEntry(3, 3, Updated),
+		newEntry(4, 4, Updated),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_BackslashSeparators(t *testing.T) {
+	old := []string{`lib\x86\libfoo.so.1`, `lib\x86\libbar.so.1.2`, `bin\tool.exe`, `.\lib\\libbaz.so.3`}
+	cur := []string{"lib/x86/libfoo.so.1", "lib/x86/libbar.so.1.3", `bin/tool.exe`, "lib/libbaz.so.4"}
+
+	r := Diff(old, cur)
+	if r.Count(Unchanged)+r.Count(Updated) != 0 {
+		t.Fatalf("Diff: %v, want no matches", r.Summary())
+	}
+
+	r = mustDiff(t, old, cur, Options{BackslashSeparators: true, NormalizePaths: true})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Updated),
+		newEntry(2, 2, Unchanged),
+		newEntry(3, 3, Updated),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_Prefix(t *testing.T) {
+	old := []string{"rootfs-a/usr/bin/ls", "rootfs-a/usr/lib/libfoo.so.1", "rootfs-a/etc/old.conf", "README"}
+	cur := []string{"rootfs-b/usr/bin/ls", "rootfs-b/usr/lib/libfoo.so.2", "rootfs-b/etc/new.conf", "README"}
+
+	r := mustDiff(t, old, cur, Options{OldPrefix: "rootfs-a/", NewPrefix: "rootfs-b/"})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Updated),
+		newEntry(2, null, Removed),
+		newEntry(3, 3, Unchanged), // Paths without the prefix are kept
+		newEntry(null, 2, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+
+	// Without the prefixes nothing but README reconciles.
+	r = Diff(old, cur)
+	if r.Count(Unchanged) != 1 || r.Count(Updated) != 0 {
+		t.Errorf("Diff: unchanged=%d updated=%d, want 1 and 0", r.Count(Unchanged), r.Count(Updated))
+	}
+}
+
+func TestDiffWithOptions_Content(t *testing.T) {
+	old := []string{"etc/app.conf", "etc/static.conf", "lib/libfoo.so.1"}
+	cur := []string{"etc/app.conf", "etc/static.conf", "lib/libfoo.so.2"}
+
+	opts := Options{
+		OldContent: []uint64{1, 2, 3},
+		CurContent: []uint64{9, 2, 3},
+	}
+
+	r := mustDiff(t, old, cur, opts)
+
+	want := map[uint32]Status{0: ContentChanged, 1: Unchanged, 2: Updated}
+	for s, e := range r.All() {
+		if s != want[e.Old()] {
+			t.Errorf("%s: got status %d, want %d", old[e.Old()], s, want[e.Old()])
+		}
+	}
+
+	if r.Count(ContentChanged) != 1 || r.Count(Unchanged) != 1 || r.Count(Updated) != 1 {
+		t.Errorf("counts: content=%d unchanged=%d updated=%d, want 1, 1, 1",
+			r.Count(ContentChanged), r.Count(Unchanged), r.Count(Updated))
+	}
+
+	// Content hashes of the wrong length are rejected.
+	for _, o := range []Options{
+		{OldContent: opts.OldContent, CurContent: opts.CurContent[:2]},
+		{OldContent: append(opts.OldContent, 4), CurContent: opts.CurContent},
+		{CurContent: opts.CurContent[:2]},
+	} {
+		if r, err := DiffWithOptions(old, cur, o); !errors.Is(err, ErrContentLength) || r != nil {
+			t.Errorf("mismatched content lengths %d/%d: result = %v, error = %v, want ErrContentLength",
+				len(o.OldContent), len(o.CurContent), r, err)
+		}
+	}
+}
+
+func TestDiffWithOptions_DetectRenames(t *testing.T) {
+	old := []string{"etc/oldname.conf", "etc/a.txt", "etc/empty.log", "lib/libfoo.so.1", "etc/gone.conf"}
+	cur := []string{"lib/libfoo.so.2", "etc/newname.conf", "etc/b.txt", "etc/empty.json", "etc/other.conf"}
+
+	opts := Options{
+		OldContent:    []uint64{1, 2, 0, 3, 4},
+		CurContent:    []uint64{3, 1, 5, 0, 6},
+		DetectRenames: true,
+	}
+
+	r := mustDiff(t, old, cur, opts)
+
+	want := []Entry{
+		newEntry(0, 1, Renamed),    // same content and extension
+		newEntry(1, null, Removed), // different content
+		newEntry(2, null, Removed), // same (empty) content but a different extension
+		newEntry(3, 0, Updated),
+		newEntry(4, null, Removed),
+		newEntry(null, 2, Added),
+		newEntry(null, 3, Added),
+		newEntry(null, 4, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %v, want %v", r.E, want)
+	}
+	if r.Count(Renamed) != 1 || r.Count(Removed) != 3 || r.Count(Added) != 3 || r.Count(Updated) != 1 {
+		t.Errorf("counts = %v, want 1 renamed, 3 removed, 3 added, 1 updated", r.Summary())
+	}
+
+	// Without the option (or without content hashes) nothing is renamed.
+	opts.DetectRenames = false
+	if r := mustDiff(t, old, cur, opts); r.Count(Renamed) != 0 || r.Count(Removed) != 4 {
+		t.Errorf("without DetectRenames: %v", r.Summary())
+	}
+	if r := mustDiff(t, old, cur, Options{DetectRenames: true}); r.Count(Renamed) != 0 || r.Count(Removed) != 4 {
+		t.Errorf("without content: %v", r.Summary())
+	}
+}
+
+func TestDiffWithOptions_DetectRenamesDuplicates(t *testing.T) {
+	// Each new file is only used once and the lowest index is preferred.
+	old := []string{"a.txt", "b.txt", "c.txt"}
+	cur := []string{"x.txt", "y.txt"}
+
+	r := mustDiff(t, old, cur, Options{
+		OldContent:    []uint64{7, 7, 7},
+		CurContent:    []uint64{7, 7},
+		DetectRenames: true,
+		MaxEntries:    3,
+	})
+
+	want := []Entry{newEntry(0, 0, Renamed), newEntry(1, 1, Renamed), newEntry(2, null, Removed)}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %v, want %v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_IdentityFirst(t *testing.T) {
+	old := []string{"libfoo.so.1"}
+	cur := []string{"libfoo.so.2", "libfoo.so.1"}
+
+	r := mustDiff(t, old, cur, Options{})
+	if r.Count(Unchanged) != 1 || r.Count(Added) != 1 || r.E[0].New() != 1 {
+		t.Errorf("exact first: unchanged=%d added=%d entry=%+v, want libfoo.so.1 Unchanged",
+			r.Count(Unchanged), r.Count(Added), r.E[0])
+	}
+
+	r = mustDiff(t, old, cur, Options{IdentityFirst: true})
+	if r.Count(Updated) != 1 || r.Count(Added) != 1 || r.E[0].New() != 0 {
+		t.Errorf("identity first: updated=%d added=%d entry=%+v, want libfoo.so.2 Updated",
+			r.Count(Updated), r.Count(Added), r.E[0])
+	}
+
+	// Identical names matched by identity are still Unchanged.
+	r = mustDiff(t, []string{"libbar.so.1"}, []string{"libbar.so.1"}, Options{IdentityFirst: true})
+	if r.Count(Unchanged) != 1 {
+		t.Errorf("identity first: unchanged=%d, want 1", r.Count(Unchanged))
+	}
+}
+
+func TestDiffWithOptions_IdentityHashes(t *testing.T) {
+	// Only the first new file of an identity is considered during the main pass,
+	// so the second pair of versions is reported as Removed and Added.
+	old := []string{"libfoo.so.1", "libfoo.so.2", "other.txt"}
+	cur := []string{"libfoo.so.3", "libfoo.so.4", "another.txt"}
+
+	r := mustDiff(t, old, cur, Options{})
+	if r.IdentityHashes != nil {
+		t.Fatal("IdentityHashes populated without being requested")
+	}
+
+	r = mustDiff(t, old, cur, Options{IdentityHashes: true})
+	if len(r.IdentityHashes) != len(r.E) {
+		t.Fatalf("len(IdentityHashes) = %d, want %d", len(r.IdentityHashes), len(r.E))
+	}
+
+	removed := map[uint64]uint32{}
+	for i, e := range r.E {
+		if e.Status() == Removed {
+			removed[r.IdentityHashes[i]] = e.Old()
+		}
+	}
+
+	var pairs [][2]string
+	for i, e := range r.E {
+		if e.Status() != Added {
+			continue
+		}
+		if o, ok := removed[r.IdentityHashes[i]]; ok {
+			pairs = append(pairs, [2]string{old[o], cur[e.New()]})
+		}
+	}
+
+	if len(pairs) != 1 || pairs[0] != [2]string{"libfoo.so.2", "libfoo.so.4"} {
+		t.Errorf("recovered pairs = %v, want [[libfoo.so.2 libfoo.so.4]]", pairs)
+	}
+
+	// One-sided inputs still populate the hashes.
+	r = mustDiff(t, nil, cur, Options{IdentityHashes: true})
+	if len(r.IdentityHashes) != len(cur) {
+		t.Errorf("one-sided: len(IdentityHashes) = %d, want %d", len(r.IdentityHashes), len(cur))
+	}
+}
+
+func TestDiffWithOptions_OpamStyle(t *testing.T) {
+	old := []string{"packages/dune.3.14.0", "packages/lwt.5.7.0~beta", "cabal/text-2.0.2"}
+	cur := []string{"packages/dune.3.15.1", "packages/lwt.5.7.0", "cabal/text-2.1.1"}
+
+	// Cabal-style names are always handled by the Suffix matcher.
+	r := Diff(old, cur)
+	if r.Count(Updated) != 1 || r.Count(Removed) != 2 {
+		t.Errorf("Diff: updated=%d removed=%d, want 1 and 2", r.Count(Updated), r.Count(Removed))
+	}
+
+	r = mustDiff(t, old, cur, Options{OpamStyle: true})
+	if r.Count(Updated) != 3 {
+		t.Errorf("DiffWithOptions: updated=%d, want 3", r.Count(Updated))
+	}
+}
+
+func TestDiffWithOptions_MaxEntries(t *testing.T) {
+	old := []string{"libfoo.so.1", "a.txt", "b.txt"}
+	cur := []string{"libfoo.so.2", "c.txt", "d.txt"}
+
+	// 3 old entries + 2 additions.
+	if r := mustDiff(t, old, cur, Options{MaxEntries: 5}); len(r.E) != 5 {
+		t.Fatalf("len(E) = %d, want 5", len(r.E))
+	}
+
+	tests := []struct {
+		name     string
+		old, cur []string
+	}{
+		{"additions", old, cur},
+		{"lower bound", old, append(cur, "e.txt", "f.txt", "g.txt")},
+		{"one-sided", nil, append(cur, "e.txt", "f.txt", "g.txt")},
+	}
+
+	for _, tt := range tests {
+		r, err := DiffWithOptions(tt.old, tt.cur, Options{MaxEntries: 4})
+		if !errors.Is(err, ErrTooManyEntries) {
+			t.Errorf("%s: error = %v, want ErrTooManyEntries", tt.name, err)
+		}
+		if r != nil {
+			t.Errorf("%s: result = %v, want nil", tt.name, r)
+		}
+	}
+
+	// Renames are paired before the exact count is checked: a.txt is renamed to c.txt.
+	opts := Options{
+		OldContent:    []uint64{1, 2, 3},
+		CurContent:    []uint64{9, 2, 8},
+		DetectRenames: true,
+		MaxEntries:    4,
+	}
+	if r := mustDiff(t, old, cur, opts); len(r.E) != 4 || r.Count(Renamed) != 1 {
+		t.Errorf("renames: len(E) = %d, renamed = %d, want 4 and 1", len(r.E), r.Count(Renamed))
+	}
+
+	opts.MaxEntries = 3
+	if _, err := DiffWithOptions(old, cur, opts); !errors.Is(err, ErrTooManyEntries) {
+		t.Errorf("renames: error = %v, want ErrTooManyEntries", err)
+	}
+}
+
+// buildID matches "name.<build-id>.ext" where the build ID is ephemeral, keeping
+// the name and extension as a two-span identity.
+func buildID(name []byte) (j, s, e int) {
+	first, last := bytes.IndexByte(name, '.'), bytes.LastIndexByte(name, '.')
+	if first <= 0 || last-first < 9 {
+		return 0, 0, 0
+	}
+
+	return first, last, len(name)
+}
+
+func TestDiffWithOptions_TwoSpanMatcher(t *testing.T) {
+	old := []string{"app.1a2b3c4d.dat", "app.1a2b3c4d.idx", "vendor.deadbeef.dat", "libfoo.so.1"}
+	cur := []string{"app.99ff00aa.dat", "app.99ff00aa.idx", "vendor.cafebabe.dat", "libfoo.so.2"}
+
+	r := Diff(old, cur)
+	if r.Count(Removed) != 3 {
+		t.Fatalf("Diff: removed=%d, want 3", r.Count(Removed))
+	}
+
+	// Invalid spans are ignored and unmatched names fall back to the built-in matchers.
+	invalid := func(name []byte) (int, int, int) { return len(name) + 1, 0, 0 }
+
+	r = mustDiff(t, old, cur, Options{Matchers: []Matcher{invalid, buildID}})
+	for _, e := range r.E {
+		if e.Status() != Updated || e.Old() != e.New() {
+			t.Errorf("entry %+v: want Updated with matching indices", e)
+		}
+	}
+}
+
+// atVersion matches "name@VERSION" names, keeping the name as the identity.
+func atVersion(name []byte) (j, s, e int) {
+	if at := bytes.LastIndexByte(name, '@'); at > 0 && at+1 < len(name) && name[at+1]-'0' < 10 {
+		return at, 0, 0
+	}
+
+	return 0, 0, 0
+}
+
+func TestDiffWithOptions_CustomMatcher(t *testing.T) {
+	old := []string{"foo@1.2.3", "bar@2.0.0", "libfoo.so.1", "pkg@1.0.0-1.0"}
+	cur := []string{"foo@1.3.0", "bar@2.1.0", "libfoo.so.2", "pkg@2.0.0-1.0"}
+
+	r := Diff(old, cur)
+	if r.Count(Updated) != 1 {
+		t.Fatalf("Diff: updated=%d, want 1", r.Count(Updated))
+	}
+
+	// Custom matchers are tried before the built-in matchers by default,
+	// so "pkg@1.0.0-1.0" is matched by atVersion rather than Suffix.
+	r = mustDiff(t, old, cur, Options{Matchers: []Matcher{atVersion}})
+	if r.Count(Updated) != 4 {
+		t.Errorf("DiffWithOptions: updated=%d, want 4", r.Count(Updated))
+	}
+
+	// With MatchersLast, Suffix recognizes "pkg@1.0.0-1.0" first ("pkg@1.0.0" and "pkg@2.0.0" differ).
+	r = mustDiff(t, old, cur, Options{Matchers: []Matcher{atVersion}, MatchersLast: true})
+	if r.Count(Updated) != 3 || r.E[3].Status() != Removed {
+		t.Errorf("MatchersLast: updated=%d entry=%v, want 3 and pkg Removed", r.Count(Updated), r.E[3])
+	}
+}
+
+// swapped matches "prefix@suffix" names as a two-span identity of prefix and suffix.
+func swapped(name []byte) (j, s, e int) {
+	if at := bytes.IndexByte(name, '@'); at > 0 {
+		return at, at + 1, len(name)
+	}
+
+	return 0, 0, 0
+}
+
+func TestConcatSpans_Collisions(t *testing.T) {
+	// Embedded names whose prefix and suffix are identical (XOR cancels them out to zero),
+	// ordinary Embedded names, and swapped two-span names from a custom matcher.
+	var corpus []string
+	for i := range 50_000 {
+		tok := token(i)
+		corpus = append(corpus,
+			fmt.Sprintf(".%s.1.2.3.%s", tok, tok),
+			fmt.Sprintf("%s.1.2.3.ext", tok),
+			fmt.Sprintf("%s@%s", tok, token(i+1)),
+			fmt.Sprintf("%s@%s", token(i+1), tok),
+		)
+	}
+
+	collisions := func(cfg *identity.Config) int {
+		ids := make(map[uint64]string, len(corpus))
+		var n int
+		for _, f := range corpus {
+			j, s, e := cfg.Spans([]byte(f))
+			id := f[:j] + "\x00" + f[s:e]
+
+			h, _ := cfg.Hash(f, seed)
+			if prev, ok := ids[h]; ok && prev != id {
+				n++
+				continue
+			}
+			ids[h] = id
+		}
+		return n
+	}
+
+	xor := collisions(&identity.Config{Matchers: []identity.Matcher{swapped}})
+	concat := collisions(&identity.Config{Matchers: []identity.Matcher{swapped}, ConcatSpans: true})
+	t.Logf("identity hash collisions over %d names: xor=%d (%.2f%%) concat=%d (%.2f%%)",
+		len(corpus), xor, 100*float64(xor)/float64(len(corpus)), concat, 100*float64(concat)/float64(len(corpus)))
+
+	if xor == 0 || concat != 0 {
+		t.Errorf("collisions: xor=%d concat=%d, want xor > 0 and concat == 0", xor, concat)
+	}
+}
+
+// token returns a lowercase alphabetic token for i.
+func token(i int) string {
+	b := []byte{'a'}
+	for ; i > 0; i /= 26 {
+		b = append(b, byte('a'+i%26))
+	}
+
+	return string(b)
+}
+
+func TestDiffWithOptions_ConcatSpans(t *testing.T) {
+	old, cur := genMixed(10_000)
+
+	want := Diff(old, cur)
+	got := mustDiff(t, old, cur, Options{ConcatSpans: true})
+
+	if !slices.Equal(got.E, want.E) {
+		t.Error("ConcatSpans changed the result")
+	}
+}
+
+func TestHasDigest(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"sha256:4f3a9c1b", true},
+		{"blobs/sha512:4f3a9c1b", true},
+		{"a/b/sha256:x/c", true},
+		{"ubuntu@sha256:4f3a9c1b", false}, // image reference
+		{"blobs/sha256/4f3a9c1b", false},  // no colon
+		{"blobs/xsha256:4f3a9c1b", false},
+		{"sha1:4f3a9c1b", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := identity.HasDigest([]byte(tt.input)); got != tt.want {
+			t.Errorf("HasDigest(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiffWithOptions_OpaqueDigests(t *testing.T) {
+	digest := func(c byte) string { return strings.Repeat(string(c), 64) }
+	old := []string{
+		"blobs/sha256:" + digest('a'),
+		"blobs/sha512:4f3a9c1b-1.0",
+		"sha256:4f3a9c1b.0a1b2c3d.js",
+		"index.json",
+		"ubuntu@sha256:" + digest('c'),
+	}
+	cur := []string{
+		"blobs/sha256:" + digest('b'),
+		"blobs/sha512:4f3a9c1b-2.0",
+		"sha256:4f3a9c1b.9f8e7d6c.js",
+		"index.json",
+		"ubuntu@sha256:" + digest('d'),
+	}
+
+	// Digests that look like versioned names collapse by default.
+	r := Diff(old, cur)
+	want := []Status{Removed, Updated, Updated, Unchanged, Updated}
+	for i, s := range want {
+		if r.E[i].Status() != s {
+			t.Errorf("Diff entry %d: status = %v, want %v", i, r.E[i].Status(), s)
+		}
+	}
+
+	// With OpaqueDigests every digest is Removed and Added, while image references
+	// still reconcile by repository.
+	r = mustDiff(t, old, cur, Options{OpaqueDigests: true})
+	want = []Status{Removed, Removed, Removed, Unchanged, Updated}
+	for i, s := range want {
+		if r.E[i].Status() != s {
+			t.Errorf("OpaqueDigests entry %d: status = %v, want %v", i, r.E[i].Status(), s)
+		}
+	}
+	if r.Count(Added) != 3 || r.Count(Updated) != 1 {
+		t.Errorf("counts: Added = %d, Updated = %d, want 3 and 1", r.Count(Added), r.Count(Updated))
+	}
+
+	// OpaqueDigests takes precedence over custom matchers.
+	all := func(name []byte) (int, int, int) { return 1, 0, 0 }
+	r = mustDiff(t, old[:1], cur[:1], Options{Matchers: []Matcher{all}, OpaqueDigests: true})
+	if r.Count(Updated) != 0 {
+		t.Errorf("Updated = %d with a custom matcher, want 0", r.Count(Updated))
+	}
+}
+
+func TestDiffWithOptions_CaseInsensitive(t *testing.T) {
+	old := []string{"Foo.txt", "usr/lib/LibFoo.so.1", "README"}
+	cur := []string{"foo.txt", "usr/lib/libfoo.so.2", "readme"}
+
+	r := Diff(old, cur)
+	if r.Count(Removed) != 3 || r.Count(Added) != 3 {
+		t.Fatalf("Diff: removed=%d added=%d, want 3 and 3", r.Count(Removed), r.Count(Added))
+	}
+
+	r = mustDiff(t, old, cur, Options{CaseInsensitive: true})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Updated),
+		newEntry(2, 2, Unchanged),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_CaseInsensitiveMatchers(t *testing.T) {
+	old := []string{
+		"usr/lib/LIBSSL.so.3",
+		"usr/lib/libCrypto.so.1.1",
+		"usr/share/App/Update.sh",
+		"usr/bin/Tool-1.2.3.py",
+		"Ä.txt",
+	}
+	cur := []string{
+		"usr/lib/libssl.so.3",
+		"usr/lib/libcrypto.so.3",
+		"usr/share/app/update.sh",
+		"usr/bin/tool-1.3.0.py",
+		"ä.txt",
+	}
+
+	r := mustDiff(t, old, cur, Options{CaseInsensitive: true})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Updated),
+		newEntry(2, 2, Unchanged),
+		newEntry(3, 3, Updated),
+		newEntry(4, null, Removed), // Only ASCII is folded
+		newEntry(null, 4, Added),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestLowerASCII(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"foo.txt":       "foo.txt",
+		"Usr/Lib/X.SO":  "usr/lib/x.so",
+		"ÄBC":           "Äbc",
+		"[@Z`]":         "[@z`]",
+		"straße/README": "straße/readme",
+	}
+
+	for in, want := range tests {
+		if got := lowerASCII(in); got != want {
+			t.Errorf("lowerASCII(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDiffWithOptions_TieBreak(t *testing.T) {
+	old := []string{"libfoo.so.1"}
+	cur := []string{"libfoo.so.2", "a.txt", "libfoo.so.2", "b.txt", "libfoo.so.2"}
+
+	tests := []struct {
+		tb   TieBreak
+		want uint32
+	}{
+		{LowestIndex, 0},
+		{HighestIndex, 4},
+	}
+
+	for _, tt := range tests {
+		opts := Options{TieBreak: tt.tb}
+		for workers := 1; workers <= len(cur); workers++ {
+			r, err := diff(old, cur, workers, &opts)
+			if err != nil {
+				t.Fatalf("diff() error = %v", err)
+			}
+			if e := r.E[0]; e.New() != tt.want || e.Status() != Updated {
+				t.Errorf("TieBreak=%d workers=%d: matched %+v, want new index %d", tt.tb, workers, e, tt.want)
+			}
+		}
+	}
+}
+
+func TestDiffWithOptions_TrustedPairs(t *testing.T) {
+	old := []string{"a.txt", "b.txt", "libfoo.so.1", "c.txt"}
+	cur := []string{"b.txt", "a.txt", "libfoo.so.2", "renamed.txt"}
+
+	opts := Options{TrustedPairs: []IndexPair{
+		{Old: 3, New: 3},  // trusted despite the different names
+		{Old: 0, New: 3},  // new file already claimed by the pair above
+		{Old: 1, New: 99}, // out of range
+	}}
+	r := mustDiff(t, old, cur, opts)
+
+	want := []Entry{
+		newEntry(0, 1, Unchanged),
+		newEntry(1, 0, Unchanged),
+		newEntry(2, 2, Updated),
+		newEntry(3, 3, Unchanged),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+
+	// Content hashes still apply to trusted pairs.
+	opts.OldContent = []uint64{1, 2, 3, 4}
+	opts.CurContent = []uint64{2, 1, 3, 5}
+	r = mustDiff(t, old, cur, opts)
+
+	want[3] = newEntry(3, 3, ContentChanged)
+	if !slices.Equal(r.E, want) {
+		t.Errorf("with content: entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestStripExtensions(t *testing.T) {
+	exts := []string{".gz", ".xz", ".zst", ".tar"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"foo-1.2.3.tar.gz", "foo-1.2.3"},
+		{"foo-1.2.3.tar.zst", "foo-1.2.3"},
+		{"foo.gz.xz", "foo"},
+		{"dir/.gz", "dir/.gz"}, // file name is never stripped to nothing
+		{".tar.gz", ".tar"},
+		{"foo.tgz", "foo.tgz"},
+	}
+
+	for _, tt := range tests {
+		if got := stripExtensions(tt.input, exts); got != tt.want {
+			t.Errorf("stripExtensions(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDiffWithOptions_IgnoreExtensions(t *testing.T) {
+	old := []string{"foo-1.2.3.tar.gz", "bar-1.0.tar.xz", "libbaz.so.1.gz", "data.json.gz"}
+	cur := []string{"foo-1.2.3.tar.zst", "bar-1.1.tar.gz", "libbaz.so.2.zst", "data.json.zst"}
+
+	// Versioned names already reconcile since their extensions are not part of the identity.
+	r := Diff(old, cur)
+	if r.Count(Updated) != 3 || r.Count(Removed) != 1 {
+		t.Fatalf("Diff: updated=%d removed=%d, want 3 and 1", r.Count(Updated), r.Count(Removed))
+	}
+
+	r = mustDiff(t, old, cur, Options{IgnoreExtensions: []string{".gz", ".xz", ".zst"}})
+	want := []Entry{
+		newEntry(0, 0, Unchanged),
+		newEntry(1, 1, Updated),
+		newEntry(2, 2, Updated),
+		newEntry(3, 3, Unchanged),
+	}
+	if !slices.Equal(r.E, want) {
+		t.Errorf("entries = %+v, want %+v", r.E, want)
+	}
+}
+
+func TestDiffWithOptions_WorkStealing(t *testing.T) {
+	old, cur := genMixed(10_000)
+	want := Diff(old, cur)
+
+	for _, workers := range []int{1, 3, 8} {
+		got, err := diff(old, cur, workers, &Options{WorkStealing: true})
+		if err != nil {
+			t.Fatalf("diff() error = %v", err)
+		}
+
+		if !slices.Equal(got.E, want.E) {
+			t.Errorf("workers=%d: WorkStealing changed the entries", workers)
+		}
+		for s := range numStatuses {
+			if got.C[s].Load() != want.C[s].Load() {
+				t.Errorf("workers=%d: count %d = %d, want %d", workers, s, got.C[s].Load(), want.C[s].Load())
+			}
+		}
+	}
+}
+
+func TestDiffWithOptions_Seed(t *testing.T) {
+	old, cur := genMixed(5_000)
+	want := Diff(old, cur)
+
+	s := maphash.MakeSeed()
+	a := mustDiff(t, old, cur, Options{Seed: s, IdentityHashes: true})
+	b := mustDiff(t, old, cur, Options{Seed: s, IdentityHashes: true})
+
+	if !slices.Equal(a.E, b.E) || !slices.Equal(a.IdentityHashes, b.IdentityHashes) {
+		t.Error("diffs with the same seed differ")
+	}
+	if !slices.Equal(a.E, want.E) {
+		t.Error("Seed changed the entries")
+	}
+
+	// The seed takes effect: identity hashes differ from those of the default seed.
+	c := mustDiff(t, old, cur, Options{IdentityHashes: true})
+	if slices.Equal(a.IdentityHashes, c.IdentityHashes) {
+		t.Error("identity hashes do not depend on Seed")
+	}
+}
+
+func TestDiffWithOptions_Progress(t *testing.T) {
+	old, cur := genMixed(200_000)
+	want := Diff(old, cur)
+
+	for _, ws := range []bool{false, true} {
+		var mu sync.Mutex
+		var calls, last int
+
+		r := mustDiff(t, old, cur, Options{
+			WorkStealing: ws,
+			Workers:      4,
+			Progress: func(done, total int) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if total != len(old) {
+					t.Errorf("total = %d, want %d", total, len(old))
+				}
+				calls++
+				last = max(last, done)
+			},
+		})
+
+		if last != len(old) {
+			t.Errorf("stealing=%v: progress reached %d, want %d", ws, last, len(old))
+		}
+		if calls < 2 || calls > len(old)/progressInterval+1 {
+			t.Errorf("stealing=%v: %d calls, want batched calls", ws, calls)
+		}
+		if !slices.Equal(r.E, want.E) {
+			t.Errorf("stealing=%v: Progress changed the entries", ws)
+		}
+	}
+}
+
+// genSkewed generates n file pairs where the first eighth have long, versioned names that are
+// expensive to reconcile and the rest are cheap removals and additions.
+func genSkewed(n int) ([]string, []string) {
+	old := make([]string, n)
+	cur := make([]string, n)
+	dir := strings.Repeat("very/deep/directory/", 100)
+
+	for i := range n {
+		if i < n/8 {
+			old[i] = fmt.Sprintf("%sname%d.1.0.0.dat", dir, i)
+			cur[i] = fmt.Sprintf("%sname%d.1.0.1.dat", dir, i)
+		} else {
+			old[i] = fmt.Sprintf("rm/%d.txt", i)
+			cur[i] = fmt.Sprintf("add/%d.txt", i)
+		}
+	}
+
+	return old, cur
+}
+
+func BenchmarkDiffSkewed(b *testing.B) {
+	old, cur := genSkewed(200_000)
+
+	for _, ws := range []bool{false, true} {
+		b.Run(fmt.Sprintf("stealing=%v", ws), func(b *testing.B) {
+			opts := &Options{WorkStealing: ws}
+			b.ReportAllocs()
+			for range b.N {
+				diff(old, cur, 8, opts)
+			}
+		})
+	}
+}
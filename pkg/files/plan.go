@@ -0,0 +1,67 @@
+package files
+
+// OpKind is the kind of a filesystem operation in a Plan.
+type OpKind uint8
+
+const (
+	OpDelete  OpKind = iota // Delete Op.Old from the destination
+	OpReplace               // Atomically replace Op.Old with Op.New (e.g., write to a temporary file and rename)
+	OpCopy                  // Copy Op.New from the source to the destination
+)
+
+// String returns the name of the operation kind.
+func (k OpKind) String() string {
+	switch k {
+	case OpDelete:
+		return "delete"
+	case OpReplace:
+		return "replace"
+	case OpCopy:
+		return "copy"
+	default:
+		return "unknown"
+	}
+}
+
+// Op is a single filesystem operation.
+// Old is the destination path being removed or replaced and is empty for OpCopy.
+// New is the source path being written and is empty for OpDelete.
+// For OpReplace, Old and New are equal when only the content changed.
+type Op struct {
+	Kind OpKind
+	Old  string
+	New  string
+}
+
+// Plan is an ordered list of operations which transforms a directory containing
+// the old files into one containing the new files.
+type Plan struct {
+	Ops []Op
+}
+
+// SyncPlan converts the result into a Plan using the original file lists.
+//
+// Removed files are deleted, Updated, ContentChanged, and Renamed files are replaced, and Added
+// files are copied; Unchanged files need no operation. All deletions come first and all
+// copies come last so that a created path never collides with one that is about to be
+// removed. Within each kind, operations follow the order of the entries.
+func (r *Result) SyncPlan(old, cur []string) *Plan {
+	n := r.Count(Removed) + r.Count(Updated) + r.Count(ContentChanged) + r.Count(Renamed) + r.Count(Added)
+	p := &Plan{Ops: make([]Op, 0, n)}
+
+	for e := range r.Filter(Removed) {
+		p.Ops = append(p.Ops, Op{Kind: OpDelete, Old: old[e.Old()]})
+	}
+
+	for s, e := range r.All() {
+		if s == Updated || s == ContentChanged || s == Renamed {
+			p.Ops = append(p.Ops, Op{Kind: OpReplace, Old: old[e.Old()], New: cur[e.New()]})
+		}
+	}
+
+	for e := range r.Filter(Added) {
+		p.Ops = append(p.Ops, Op{Kind: OpCopy, New: cur[e.New()]})
+	}
+
+	return p
+}
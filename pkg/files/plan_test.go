@@ -0,0 +1,83 @@
+package files
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// applyPlan applies a plan to a set of file names and fails on operations
+// that reference missing files or would overwrite existing ones.
+func applyPlan(t *testing.T, files []string, p *Plan) []string {
+	t.Helper()
+
+	fs := make(map[string]bool, len(files))
+	for _, f := range files {
+		fs[f] = true
+	}
+
+	for _, op := range p.Ops {
+		switch op.Kind {
+		case OpDelete:
+			if !fs[op.Old] {
+				t.Fatalf("%s %q: file does not exist", op.Kind, op.Old)
+			}
+			delete(fs, op.Old)
+		case OpReplace:
+			if !fs[op.Old] {
+				t.Fatalf("%s %q: file does not exist", op.Kind, op.Old)
+			}
+			if op.Old != op.New && fs[op.New] {
+				t.Fatalf("%s %q -> %q: destination already exists", op.Kind, op.Old, op.New)
+			}
+			delete(fs, op.Old)
+			fs[op.New] = true
+		case OpCopy:
+			if fs[op.New] {
+				t.Fatalf("%s %q: destination already exists", op.Kind, op.New)
+			}
+			fs[op.New] = true
+		}
+	}
+
+	return slices.Sorted(maps.Keys(fs))
+}
+
+func TestSyncPlan(t *testing.T) {
+	old := []string{"libfoo.so.1", "README", "config.json", "bin/tool-1.2.3"}
+	cur := []string{"libfoo.so.2", "README", "config.json", "bin/new"}
+
+	opts := Options{
+		OldContent: []uint64{1, 2, 3, 4},
+		CurContent: []uint64{1, 2, 9, 4},
+	}
+	r := mustDiff(t, old, cur, opts)
+	p := r.SyncPlan(old, cur)
+
+	want := []Op{
+		{Kind: OpDelete, Old: "bin/tool-1.2.3"},
+		{Kind: OpReplace, Old: "libfoo.so.1", New: "libfoo.so.2"},
+		{Kind: OpReplace, Old: "config.json", New: "config.json"},
+		{Kind: OpCopy, New: "bin/new"},
+	}
+	if !slices.Equal(p.Ops, want) {
+		t.Errorf("SyncPlan() = %+v, want %+v", p.Ops, want)
+	}
+}
+
+func TestSyncPlan_Apply(t *testing.T) {
+	old, cur := genMixed(10_000)
+
+	// A removed file shares its name with an added file under IdentityFirst.
+	old = append(old, "libbar.so.1", "tmp/a.txt")
+	cur = append(cur, "libbar.so.2", "libbar.so.1")
+
+	for _, opts := range []Options{{}, {IdentityFirst: true}} {
+		r := mustDiff(t, old, cur, opts)
+
+		got := applyPlan(t, old, r.SyncPlan(old, cur))
+		if want := slices.Sorted(slices.Values(cur)); !slices.Equal(got, want) {
+			t.Errorf("IdentityFirst=%v: applied plan has %d files, want %d", opts.IdentityFirst, len(got), len(want))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// labelEscaper escapes label values per the Prometheus text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the status counts in the Prometheus text exposition format
+// as a "reconcile_entries" gauge with one sample per status, for example:
+//
+//	reconcile_entries{status="added"} 134
+//
+// The given labels are added to every sample in sorted order. Label names must be
+// valid Prometheus label names and may not be "status".
+func (r *Result) WritePrometheus(w io.Writer, labels map[string]string) error {
+	var extra strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		if !validLabel(k) || k == "status" {
+			return fmt.Errorf("invalid label name %q", k)
+		}
+		fmt.Fprintf(&extra, `,%s="%s"`, k, labelEscaper.Replace(labels[k]))
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# HELP reconcile_entries Number of reconciliation entries by status.\n")
+	bw.WriteString("# TYPE reconcile_entries gauge\n")
+
+	for s := range numStatuses {
+		fmt.Fprintf(bw, "reconcile_entries{status=%q%s} %d\n", statusNames[s], extra.String(), r.C[s].Load())
+	}
+
+	return bw.Flush()
+}
+
+// validLabel reports whether name matches [a-zA-Z_][a-zA-Z0-9_]* and is not reserved.
+func validLabel(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+
+	for i := range len(name) {
+		c := name[i]
+		if (c|32)-'a' >= 26 && c != '_' && (i == 0 || c-'0' >= 10) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResult_WritePrometheus(t *testing.T) {
+	old := []string{"libfoo.so.1", "README", "gone.txt"}
+	cur := []string{"libfoo.so.2", "README", "new.txt", "other.txt"}
+
+	var b strings.Builder
+	labels := map[string]string{"image": `cgr.dev/"static"`, "arch": "amd64\\arm64\n"}
+	if err := Diff(old, cur).WritePrometheus(&b, labels); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+
+	want := `# HELP reconcile_entries Number of reconciliation entries by status.
+# TYPE reconcile_entries gauge
+reconcile_entries{status="unchanged",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 1
+reconcile_entries{status="updated",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 1
+reconcile_entries{status="removed",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 1
+reconcile_entries{status="added",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 2
+reconcile_entries{status="content_changed",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 0
+reconcile_entries{status="renamed",arch="amd64\\arm64\n",image="cgr.dev/\"static\""} 0
+`
+	if got := b.String(); got != want {
+		t.Errorf("WritePrometheus() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestResult_WritePrometheusInvalidLabel(t *testing.T) {
+	for _, name := range []string{"", "status", "1abc", "a-b", "__name"} {
+		var b strings.Builder
+		if err := (&Result{}).WritePrometheus(&b, map[string]string{name: "x"}); err == nil {
+			t.Errorf("WritePrometheus(%q) error = nil, want error", name)
+		}
+		if b.Len() != 0 {
+			t.Errorf("WritePrometheus(%q) wrote %q, want nothing", name, b.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// readBatch is the number of old lines DiffReaders hashes and reconciles at a time.
+const readBatch = 64 << 10
+
+// DiffReaders compares two newline-delimited file lists read from old and cur.
+// Lines longer than maxLine bytes fail with an error (bufio.MaxScanTokenSize is used when
+// maxLine is not positive), as does any error from reading either input.
+//
+// All of cur is read first since its names and hashes are needed for lookups, but old is
+// streamed: its lines are hashed and reconciled in batches, so only one batch of old names
+// is held in memory at a time. The Result itself still holds one entry per file.
+// Old files are reconciled in order, so the result matches Diff with a single worker.
+func DiffReaders(old, cur io.Reader, maxLine int) (*Result, error) {
+	if maxLine <= 0 {
+		maxLine = bufio.MaxScanTokenSize
+	}
+
+	var files []string
+	sc := newLineScanner(cur, maxLine)
+	for sc.Scan() {
+		files = append(files, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading new files: %w", err)
+	}
+
+	newFiles := len(files)
+	if newFiles > maxNewFiles {
+		return nil, fmt.Errorf("%w: %d new files exceed the limit of %d", ErrTooManyEntries, newFiles, maxNewFiles)
+	}
+
+	workers := max(1, runtime.GOMAXPROCS(0))
+	opts := &Options{}
+	curHashes, curEntries := identity.HashAll(files, workers, seed)
+	tbl, _ := buildTable(curHashes, curEntries, workers, opts, nil, nil)
+
+	rc := &reconciler{
+		cur:     files,
+		table:   tbl,
+		matches: make([]atomic.Uint64, (newFiles+63)>>6), // One bit per new file
+		opts:    opts,
+	}
+
+	var entries []Entry
+	counts := make([][numStatuses]uint32, 1)
+
+	batch := make([]string, 0, readBatch)
+	reconcile := func() {
+		rc.old, rc.offset = batch, len(entries)
+		rc.oldHashes, rc.oldEntries = identity.HashAll(batch, workers, seed)
+
+		for i := range batch {
+			j, s := rc.match(i)
+			entries = append(entries, newEntry(uint32(len(entries)), j, s)) // #nosec G115
+			counts[0][s]++
+		}
+		batch = batch[:0]
+	}
+
+	sc = newLineScanner(old, maxLine)
+	for sc.Scan() {
+		if batch = append(batch, sc.Text()); len(batch) == readBatch {
+			reconcile()
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading old files: %w", err)
+	}
+	reconcile()
+
+	return merge([][]Entry{entries}, rc.additions(workers), counts, workers), nil
+}
+
+// newLineScanner returns a scanner over the lines of r allowing lines of up to maxLine bytes.
+func newLineScanner(r io.Reader, maxLine int) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, min(maxLine, 64<<10)), maxLine)
+
+	return sc
+}
@@ -0,0 +1,96 @@
+package files
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDiffReaders(t *testing.T) {
+	old := []string{"lib.so.1", "bin/foo", "doc.md", "old.txt"}
+	cur := []string{"lib.so.2", "bin/foo", "doc.md", "new.txt"}
+
+	r, err := DiffReaders(strings.NewReader(strings.Join(old, "\n")), strings.NewReader(strings.Join(cur, "\r\n")+"\n"), 0)
+	if err != nil {
+		t.Fatalf("DiffReaders() error = %v", err)
+	}
+
+	want := Diff(old, cur)
+	if !slices.Equal(r.E, want.E) {
+		t.Errorf("entries = %v, want %v", r.E, want.E)
+	}
+	if r.Summary() != want.Summary() {
+		t.Errorf("Summary() = %v, want %v", r.Summary(), want.Summary())
+	}
+}
+
+func TestDiffReaders_Batches(t *testing.T) {
+	// More old files than fit in a single batch.
+	old, cur := genMixed(readBatch + readBatch/2)
+
+	r, err := DiffReaders(strings.NewReader(strings.Join(old, "\n")), strings.NewReader(strings.Join(cur, "\n")), 0)
+	if err != nil {
+		t.Fatalf("DiffReaders() error = %v", err)
+	}
+
+	want := diffP(old, cur, 1)
+	if !slices.Equal(r.E, want.E) {
+		t.Error("entries differ from Diff")
+	}
+	if r.Summary() != want.Summary() {
+		t.Errorf("Summary() = %v, want %v", r.Summary(), want.Summary())
+	}
+}
+
+func TestDiffReaders_BatchDuplicates(t *testing.T) {
+	// Duplicate names beyond the first batch pair up by their position in the whole list.
+	old := make([]string, readBatch+2)
+	cur := make([]string, readBatch+2)
+	for i := range old {
+		old[i] = fmt.Sprintf("file%d", i)
+		cur[i] = old[i]
+	}
+	old[readBatch], old[readBatch+1] = "x", "x"
+	cur[0], cur[1] = "x", "x"
+	cur[readBatch], cur[readBatch+1] = "file0", "file1"
+
+	r, err := DiffReaders(strings.NewReader(strings.Join(old, "\n")), strings.NewReader(strings.Join(cur, "\n")), 0)
+	if err != nil {
+		t.Fatalf("DiffReaders() error = %v", err)
+	}
+
+	want := diffP(old, cur, 1)
+	for _, k := range []int{readBatch, readBatch + 1} {
+		if r.E[k] != want.E[k] {
+			t.Errorf("entry %d = %+v, want %+v", k, r.E[k], want.E[k])
+		}
+	}
+	if !slices.Equal(r.E, want.E) {
+		t.Error("entries differ from Diff")
+	}
+}
+
+func TestDiffReaders_Errors(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	if _, err := DiffReaders(strings.NewReader(long), strings.NewReader("a"), 64); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("long old line: error = %v, want bufio.ErrTooLong", err)
+	}
+	if _, err := DiffReaders(strings.NewReader("a"), strings.NewReader(long), 64); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("long new line: error = %v, want bufio.ErrTooLong", err)
+	}
+
+	errRead := errors.New("read failed")
+	if _, err := DiffReaders(iotest.ErrReader(errRead), strings.NewReader("a"), 0); !errors.Is(err, errRead) {
+		t.Errorf("failing reader: error = %v, want %v", err, errRead)
+	}
+
+	r, err := DiffReaders(strings.NewReader(""), strings.NewReader(""), 0)
+	if err != nil || len(r.E) != 0 {
+		t.Errorf("empty inputs: result = %v, error = %v", r, err)
+	}
+}
@@ -0,0 +1,85 @@
+package files
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// Reconciler diffs file lists like Diff while reusing its lookup table and match bits
+// across calls, which avoids most of the per-call allocation when diffing many small inputs.
+// The zero value is ready to use.
+//
+// A Reconciler is not safe for concurrent use: calls must be serialized, for example with a
+// mutex or by giving each goroutine its own Reconciler. Results never share memory with the
+// Reconciler, so they remain valid after later calls. The reused table keeps the capacity of
+// the largest input seen, so a Reconciler used once for a very large input retains that memory.
+type Reconciler struct {
+	scratch scratch
+}
+
+// Reconcile compares two file lists and returns the same Result as Diff.
+// Reconcile panics if cur holds more than 2^29-1 files (see Entry); ReconcileContext
+// returns ErrTooManyEntries instead.
+func (r *Reconciler) Reconcile(old, cur []string) *Result {
+	res, err := r.ReconcileContext(context.Background(), old, cur)
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
+
+// ReconcileContext is like Reconcile but stops early and returns ctx.Err() when ctx is cancelled,
+// and returns ErrTooManyEntries rather than panicking when cur holds more than 2^29-1 files.
+func (r *Reconciler) ReconcileContext(ctx context.Context, old, cur []string) (*Result, error) {
+	return diffContext(ctx, old, cur, max(1, runtime.GOMAXPROCS(0)), &Options{}, &r.scratch)
+}
+
+// scratch holds the state reused between diffs by a Reconciler.
+// A nil scratch allocates fresh state for every diff.
+type scratch struct {
+	table   table
+	matches []atomic.Uint64
+	owner   []atomic.Uint32
+}
+
+// tableFor returns an empty table sized for n keys, reusing the table of sc when it is set.
+func (sc *scratch) tableFor(n int) *table {
+	if sc == nil {
+		sc = &scratch{}
+	}
+	sc.table.reset(n)
+
+	return &sc.table
+}
+
+// bits returns n cleared words for tracking matched new files.
+func (sc *scratch) bits(n int) []atomic.Uint64 {
+	if sc == nil {
+		return make([]atomic.Uint64, n)
+	}
+
+	if cap(sc.matches) < n {
+		sc.matches = make([]atomic.Uint64, n)
+	}
+	sc.matches = sc.matches[:n]
+	clear(sc.matches)
+
+	return sc.matches
+}
+
+// owners returns n cleared slots for the old file claiming each new file.
+func (sc *scratch) owners(n int) []atomic.Uint32 {
+	if sc == nil {
+		return make([]atomic.Uint32, n)
+	}
+
+	if cap(sc.owner) < n {
+		sc.owner = make([]atomic.Uint32, n)
+	}
+	sc.owner = sc.owner[:n]
+	clear(sc.owner)
+
+	return sc.owner
+}
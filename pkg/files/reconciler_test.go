@@ -0,0 +1,79 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestReconciler(t *testing.T) {
+	var rc Reconciler
+
+	// Inputs of varying sizes, so that the reused state both grows and shrinks.
+	sizes := []int{100, 10_000, 0, 10, 1_000, 10_000, 1}
+
+	var results []*Result
+	var wants []*Result
+	for _, n := range sizes {
+		old, cur := genMixed(n)
+		results = append(results, rc.Reconcile(old, cur))
+		wants = append(wants, Diff(old, cur))
+	}
+
+	// Earlier results are not affected by later calls.
+	for i, r := range results {
+		if !slices.Equal(r.E, wants[i].E) || r.Summary() != wants[i].Summary() {
+			t.Errorf("Reconcile(genMixed(%d)) differs from Diff", sizes[i])
+		}
+	}
+
+	// A file matched in one call can be matched again in the next.
+	old, cur := []string{"libfoo.so.1", "a.txt"}, []string{"libfoo.so.2", "a.txt"}
+	for range 3 {
+		r := rc.Reconcile(old, cur)
+		if r.Count(Updated) != 1 || r.Count(Unchanged) != 1 {
+			t.Fatalf("Reconcile() = %v, want 1 updated and 1 unchanged", r.Summary())
+		}
+	}
+}
+
+func TestReconciler_ReconcileContext(t *testing.T) {
+	var rc Reconciler
+	old, cur := genMixed(5_000)
+
+	r, err := rc.ReconcileContext(context.Background(), old, cur)
+	if err != nil {
+		t.Fatalf("ReconcileContext() error = %v", err)
+	}
+	if want := Diff(old, cur); !slices.Equal(r.E, want.E) {
+		t.Error("ReconcileContext() entries differ from Diff")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r, err := rc.ReconcileContext(ctx, old, cur); !errors.Is(err, context.Canceled) || r != nil {
+		t.Errorf("cancelled ReconcileContext() = %v, %v, want context.Canceled", r, err)
+	}
+}
+
+func BenchmarkReconciler(b *testing.B) {
+	for _, n := range []int{10, 100, 1_000} {
+		old, cur := genMixed(n)
+
+		b.Run(fmt.Sprintf("n=%d/Diff", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				Diff(old, cur)
+			}
+		})
+		b.Run(fmt.Sprintf("n=%d/Reconcile", n), func(b *testing.B) {
+			var rc Reconciler
+			b.ReportAllocs()
+			for b.Loop() {
+				rc.Reconcile(old, cur)
+			}
+		})
+	}
+}
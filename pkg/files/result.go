@@ -1,7 +1,12 @@
 package files
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"iter"
+	"slices"
 	"sync/atomic"
 )
 
@@ -12,22 +17,160 @@ const (
 	Updated
 	Removed
 	Added
+	ContentChanged // Same name but different content hashes (see Options.OldContent)
+	Renamed        // Different names but the same content hash (see Options.DetectRenames)
+
+	numStatuses = int(Renamed) + 1
 )
 
+// statusStrings holds the name of each status returned by Status.String.
+var statusStrings = [numStatuses]string{"Unchanged", "Updated", "Removed", "Added", "ContentChanged", "Renamed"}
+
+// statusNames holds the encoded name of each status, used by MarshalText and the writers.
+var statusNames = [numStatuses]string{"unchanged", "updated", "removed", "added", "content_changed", "renamed"}
+
+// String returns the name of the status (e.g., "Updated"), or "Status(N)" for unknown values.
+func (s Status) String() string {
+	if int(s) < numStatuses {
+		return statusStrings[s]
+	}
+
+	return fmt.Sprintf("Status(%d)", uint8(s))
+}
+
+// MarshalText encodes the status as its name so that it is readable in JSON and YAML.
+func (s Status) MarshalText() ([]byte, error) {
+	if int(s) >= numStatuses {
+		return nil, fmt.Errorf("invalid status %d", uint8(s))
+	}
+
+	return []byte(statusNames[s]), nil
+}
+
+// UnmarshalText decodes a status encoded by MarshalText.
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if string(text) == name {
+			*s = Status(i) // #nosec G115
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown status %q", text)
+}
+
 // Entry represents a single file reconciliation result.
-// For Unchanged and Updated entries, Old and New will contain file indices.
-// For Removed entries, New will be null (using the sentinel value of 0xFFFFFFFF).
-// For Added entries, Old will be null (using the sentinel value of 0xFFFFFFFF).
+// For Unchanged, Updated, ContentChanged, and Renamed entries, Old and New will return file indices.
+// For Removed entries, New will return null (using the sentinel value of 0xFFFFFFFF).
+// For Added entries, Old will return null (using the sentinel value of 0xFFFFFFFF).
+//
+// Entries are packed into 8 bytes by storing the status in the high bits of the new index,
+// which limits the new file list to 2^29-1 (536,870,911) files.
 type Entry struct {
+	old uint32
+	new uint32 // Status in the high statusBits bits and the new index (or newNull) in the rest
+}
+
+const (
+	statusBits  = 3
+	statusShift = 32 - statusBits
+	newNull     = 1<<statusShift - 1 // Packed representation of a null new index
+	maxNewFiles = newNull            // New indices must be below the packed null sentinel
+)
+
+// newEntry packs a reconciliation result into an Entry.
+func newEntry(old, cur uint32, s Status) Entry {
+	if cur == null {
+		cur = newNull
+	}
+
+	return Entry{old: old, new: uint32(s)<<statusShift | cur}
+}
+
+// Old returns the index of the old file, or null for Added entries.
+func (e Entry) Old() uint32 { return e.old }
+
+// New returns the index of the new file, or null for Removed entries.
+func (e Entry) New() uint32 {
+	if n := e.new & newNull; n != newNull {
+		return n
+	}
+
+	return null
+}
+
+// Status returns the status of the entry.
+func (e Entry) Status() Status { return Status(e.new >> statusShift) }
+
+// jsonEntry is the JSON representation of an Entry.
+type jsonEntry struct {
 	Old    uint32
 	New    uint32
-	Status uint32
+	Status Status
+}
+
+// MarshalJSON encodes the entry as an object with Old, New, and Status fields,
+// where Status is the name of the status.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonEntry{e.Old(), e.New(), e.Status()})
+}
+
+// UnmarshalJSON decodes an entry encoded by MarshalJSON.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var j jsonEntry
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.New != null && j.New >= maxNewFiles {
+		return fmt.Errorf("invalid entry %s", data)
+	}
+
+	*e = newEntry(j.Old, j.New, j.Status)
+	return nil
+}
+
+// String formats the entry with its indices and status.
+func (e Entry) String() string {
+	return fmt.Sprintf("{Old:%d New:%d Status:%d}", e.Old(), e.New(), e.Status())
+}
+
+// NamedEntry is an Entry with its old and new file names resolved.
+// The missing side of Removed and Added entries is an empty string.
+type NamedEntry struct {
+	Entry
+	OldName string
+	NewName string
+}
+
+// named resolves the file names of an entry against the original file lists.
+func (e Entry) named(old, cur []string) NamedEntry {
+	n := NamedEntry{Entry: e}
+	if e.Old() != null {
+		n.OldName = old[e.Old()]
+	}
+	if e.New() != null {
+		n.NewName = cur[e.New()]
+	}
+	return n
 }
 
 // Result contains the final reconciliation output for a collection of old and new files.
 type Result struct {
-	E []Entry          // All Unchanged, Updated, Removed, and Added entries
-	C [4]atomic.Uint32 // Counts of the above statuses indexed by their respecive integer values
+	E []Entry                    // All Unchanged, Updated, Removed, Added, ContentChanged, and Renamed entries
+	C [numStatuses]atomic.Uint32 // Counts of the above statuses indexed by their respecive integer values
+
+	// IdentityHashes holds the identity hash of each entry aligned with E and is only
+	// populated when Options.IdentityHashes is set. Entries with an old file use its hash;
+	// Added entries use the new file's hash. Hashes are only comparable within a process.
+	IdentityHashes []uint64
+
+	// Dirs lists the versioned directories collapsed into a single Updated entry
+	// and is only populated when Options.CollapseVersionedDirs is set.
+	Dirs []CollapsedDir
+
+	// Stats counts hash collisions resolved by comparing names and is only populated
+	// when Options.Stats is set.
+	Stats *Stats
 }
 
 // Count returns the number of entries with the given status.
@@ -37,8 +180,7 @@ func (r *Result) Count(s Status) uint32 { return r.C[s].Load() }
 func (r *Result) All() iter.Seq2[Status, Entry] {
 	return func(yield func(Status, Entry) bool) {
 		for _, e := range r.E {
-			// #nosec G115
-			if !yield(Status(e.Status&0xFF), e) {
+			if !yield(e.Status(), e) {
 				return
 			}
 		}
@@ -49,8 +191,7 @@ func (r *Result) All() iter.Seq2[Status, Entry] {
 func (r *Result) Filter(s Status) iter.Seq[Entry] {
 	return func(yield func(Entry) bool) {
 		for _, e := range r.E {
-			// #nosec G115
-			if Status(e.Status&0xFF) == s {
+			if e.Status() == s {
 				if !yield(e) {
 					return
 				}
@@ -58,3 +199,124 @@ func (r *Result) Filter(s Status) iter.Seq[Entry] {
 		}
 	}
 }
+
+// FilterFunc returns an iterator over entries for which pred returns true.
+func (r *Result) FilterFunc(pred func(Status, Entry) bool) iter.Seq2[Status, Entry] {
+	return func(yield func(Status, Entry) bool) {
+		for s, e := range r.All() {
+			if pred(s, e) && !yield(s, e) {
+				return
+			}
+		}
+	}
+}
+
+// Pairs returns an iterator over the old and new file names of all entries,
+// resolved against the original old and cur slices. The missing side of Removed
+// and Added entries is an empty string.
+func (r *Result) Pairs(old, cur []string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, e := range r.E {
+			n := e.named(old, cur)
+			if !yield(n.OldName, n.NewName) {
+				return
+			}
+		}
+	}
+}
+
+// Top returns up to n entries with the given status with their names resolved
+// against old and cur. Iteration stops as soon as n entries have been collected.
+func (r *Result) Top(n int, s Status, old, cur []string) []NamedEntry {
+	if n <= 0 {
+		return nil
+	}
+
+	top := make([]NamedEntry, 0, min(n, int(r.Count(s))))
+	for e := range r.Filter(s) {
+		top = append(top, e.named(old, cur))
+		if len(top) == n {
+			break
+		}
+	}
+
+	return top
+}
+
+// Summary holds the status counts of a Result.
+type Summary struct {
+	Unchanged      uint32
+	Updated        uint32
+	Removed        uint32
+	Added          uint32
+	ContentChanged uint32
+	Renamed        uint32
+	Total          uint32 // Number of entries
+}
+
+// Summary returns the status counts of the result, reading each count once.
+func (r *Result) Summary() Summary {
+	return Summary{
+		Unchanged:      r.Count(Unchanged),
+		Updated:        r.Count(Updated),
+		Removed:        r.Count(Removed),
+		Added:          r.Count(Added),
+		ContentChanged: r.Count(ContentChanged),
+		Renamed:        r.Count(Renamed),
+		Total:          uint32(len(r.E)), // #nosec G115
+	}
+}
+
+// String formats the summary as space-separated "status=count" pairs.
+func (s Summary) String() string {
+	return fmt.Sprintf("unchanged=%d updated=%d removed=%d added=%d content_changed=%d renamed=%d total=%d",
+		s.Unchanged, s.Updated, s.Removed, s.Added, s.ContentChanged, s.Renamed, s.Total)
+}
+
+// Partition splits the entries into per-status slices in a single pass.
+// ContentChanged and Renamed entries are grouped with updated since their files need to be replaced.
+func (r *Result) Partition() (unchanged, updated, removed, added []Entry) {
+	unchanged = make([]Entry, 0, r.Count(Unchanged))
+	updated = make([]Entry, 0, r.Count(Updated)+r.Count(ContentChanged)+r.Count(Renamed))
+	removed = make([]Entry, 0, r.Count(Removed))
+	added = make([]Entry, 0, r.Count(Added))
+
+	for _, e := range r.E {
+		switch e.Status() {
+		case Unchanged:
+			unchanged = append(unchanged, e)
+		case Updated, ContentChanged, Renamed:
+			updated = append(updated, e)
+		case Removed:
+			removed = append(removed, e)
+		case Added:
+			added = append(added, e)
+		}
+	}
+
+	return unchanged, updated, removed, added
+}
+
+// Digest returns a hash summarizing the entries and counts of the result.
+// Entries are hashed in sorted order, so results with the same classifications
+// share a digest regardless of entry order. The digest is stable across processes
+// and can be used as a cache key.
+func (r *Result) Digest() uint64 {
+	keys := make([]uint64, len(r.E))
+	for i, e := range r.E {
+		keys[i] = uint64(e.old)<<32 | uint64(e.new)
+	}
+	slices.Sort(keys)
+
+	h := fnv.New64a()
+	buf := make([]byte, 0, 8*(numStatuses+len(keys)))
+	for s := range numStatuses {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(r.C[s].Load()))
+	}
+	for _, k := range keys {
+		buf = binary.LittleEndian.AppendUint64(buf, k)
+	}
+	h.Write(buf)
+
+	return h.Sum64()
+}
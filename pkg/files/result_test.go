@@ -1,6 +1,11 @@
 package files
 
-import "testing"
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"unsafe"
+)
 
 func TestResult_Iterators(t *testing.T) {
 	old := []string{"a.so.1", "b.so.1", "old.txt"}
@@ -25,3 +30,280 @@ func TestResult_Iterators(t *testing.T) {
 		t.Errorf("Filter(Updated) yielded %d, want %d", updated, r.Count(Updated))
 	}
 }
+
+func TestStatus_String(t *testing.T) {
+	tests := []struct {
+		s    Status
+		want string
+	}{
+		{Unchanged, "Unchanged"},
+		{Updated, "Updated"},
+		{Removed, "Removed"},
+		{Added, "Added"},
+		{ContentChanged, "ContentChanged"},
+		{Renamed, "Renamed"},
+		{Status(6), "Status(6)"},
+		{Status(255), "Status(255)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("Status(%d).String() = %q, want %q", uint8(tt.s), got, tt.want)
+		}
+	}
+}
+
+func TestStatus_MarshalText(t *testing.T) {
+	in := map[string]Status{"a": Updated, "b": ContentChanged}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"a":"updated","b":"content_changed"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var out map[string]Status
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out["a"] != Updated || out["b"] != ContentChanged {
+		t.Errorf("Unmarshal() = %v, want %v", out, in)
+	}
+
+	if _, err := json.Marshal(Status(6)); err == nil {
+		t.Error("Marshal(Status(6)) succeeded, want error")
+	}
+	var s Status
+	if err := json.Unmarshal([]byte(`"Moved"`), &s); err == nil {
+		t.Error(`Unmarshal("Moved") succeeded, want error`)
+	}
+}
+
+func TestResult_Summary(t *testing.T) {
+	old := []string{"lib.so.1", "bin/foo", "doc.md", "old.txt"}
+	cur := []string{"lib.so.2", "bin/foo", "doc.md", "new.txt"}
+
+	r := Diff(old, cur)
+	got := r.Summary()
+
+	want := Summary{
+		Unchanged:      r.Count(Unchanged),
+		Updated:        r.Count(Updated),
+		Removed:        r.Count(Removed),
+		Added:          r.Count(Added),
+		ContentChanged: r.Count(ContentChanged),
+		Renamed:        r.Count(Renamed),
+		Total:          uint32(len(r.E)),
+	}
+	if got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+	if got.Unchanged+got.Updated+got.Removed+got.Added+got.ContentChanged+got.Renamed != got.Total {
+		t.Errorf("Summary() counts do not add up to Total: %+v", got)
+	}
+
+	if s, want := got.String(), "unchanged=2 updated=1 removed=1 added=1 content_changed=0 renamed=0 total=5"; s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+
+	if got := Diff(nil, nil).Summary(); got != (Summary{}) {
+		t.Errorf("empty Summary() = %+v, want zero", got)
+	}
+}
+
+func TestResult_Top(t *testing.T) {
+	old := []string{"a.so.1", "b.so.1", "c.so.1", "old.txt"}
+	cur := []string{"a.so.2", "b.so.2", "c.so.2", "new.txt"}
+
+	r := Diff(old, cur)
+
+	top := r.Top(2, Updated, old, cur)
+	if len(top) != 2 {
+		t.Fatalf("Top(2, Updated) returned %d entries, want 2", len(top))
+	}
+
+	for _, e := range top {
+		if e.OldName != old[e.Old()] || e.NewName != cur[e.New()] {
+			t.Errorf("Top(2, Updated) entry %+v has unresolved names", e)
+		}
+	}
+
+	if got := r.Top(10, Removed, old, cur); len(got) != 1 || got[0].OldName != "old.txt" || got[0].NewName != "" {
+		t.Errorf("Top(10, Removed) = %+v, want old.txt only", got)
+	}
+
+	if got := r.Top(10, Added, old, cur); len(got) != 1 || got[0].OldName != "" || got[0].NewName != "new.txt" {
+		t.Errorf("Top(10, Added) = %+v, want new.txt only", got)
+	}
+
+	if got := r.Top(0, Updated, old, cur); got != nil {
+		t.Errorf("Top(0, Updated) = %+v, want nil", got)
+	}
+}
+
+func TestResult_Pairs(t *testing.T) {
+	old := []string{"etc/app.conf", "etc/static.conf", "lib/libfoo.so.1", "old.txt"}
+	cur := []string{"etc/app.conf", "etc/static.conf", "lib/libfoo.so.2", "new.txt"}
+
+	r := mustDiff(t, old, cur, Options{OldContent: []uint64{1, 2, 3, 4}, CurContent: []uint64{9, 2, 3, 4}})
+
+	var got [][2]string
+	for o, n := range r.Pairs(old, cur) {
+		got = append(got, [2]string{o, n})
+	}
+
+	want := [][2]string{
+		{"etc/app.conf", "etc/app.conf"},       // ContentChanged
+		{"etc/static.conf", "etc/static.conf"}, // Unchanged
+		{"lib/libfoo.so.1", "lib/libfoo.so.2"}, // Updated
+		{"old.txt", ""},                        // Removed
+		{"", "new.txt"},                        // Added
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Pairs() = %v, want %v", got, want)
+	}
+
+	// Iteration stops early.
+	var n int
+	for range r.Pairs(old, cur) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Pairs() yielded %d pairs after break, want 1", n)
+	}
+}
+
+func TestResult_FilterFunc(t *testing.T) {
+	old := []string{"a.so.1", "b.so.1", "c.txt", "d.so.1"}
+	cur := []string{"a.so.2", "b.so.2", "c.txt", "d.so.2"}
+
+	r := Diff(old, cur)
+
+	var got []uint32
+	for s, e := range r.FilterFunc(func(s Status, e Entry) bool {
+		return s == Updated && e.Old() >= 1
+	}) {
+		if s != Updated {
+			t.Errorf("FilterFunc yielded status %d, want Updated", s)
+		}
+		got = append(got, e.Old())
+	}
+
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("FilterFunc yielded old indices %v, want [1 3]", got)
+	}
+
+	var n int
+	for range r.FilterFunc(func(Status, Entry) bool { return true }) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("FilterFunc did not stop early: %d", n)
+	}
+}
+
+func TestResult_Partition(t *testing.T) {
+	old, cur := genMixed(10_000)
+	opts := Options{OldContent: make([]uint64, len(old)), CurContent: make([]uint64, len(cur))}
+	for e := range Diff(old, cur).Filter(Unchanged) {
+		opts.CurContent[e.New()] = 1
+		break
+	}
+
+	r := mustDiff(t, old, cur, opts)
+	unchanged, updated, removed, added := r.Partition()
+
+	got := [4]int{len(unchanged), len(updated), len(removed), len(added)}
+	want := [4]int{
+		int(r.Count(Unchanged)),
+		int(r.Count(Updated) + r.Count(ContentChanged)),
+		int(r.Count(Removed)),
+		int(r.Count(Added)),
+	}
+	if got != want || r.Count(ContentChanged) != 1 {
+		t.Errorf("partition sizes = %v, want %v (content changed = %d)", got, want, r.Count(ContentChanged))
+	}
+
+	for _, e := range removed {
+		if e.Status() != Removed {
+			t.Fatalf("removed partition contains %+v", e)
+		}
+	}
+}
+
+func TestEntry_Packing(t *testing.T) {
+	if size := unsafe.Sizeof(Entry{}); size != 8 {
+		t.Errorf("Sizeof(Entry) = %d, want 8", size)
+	}
+
+	tests := []struct {
+		old, cur uint32
+		s        Status
+	}{
+		{0, 0, Unchanged},
+		{1, 2, Updated},
+		{null - 1, null, Removed},
+		{null, maxNewFiles - 1, Added},
+		{7, 7, ContentChanged},
+		{maxNewFiles, maxNewFiles - 1, Renamed},
+	}
+
+	for _, tt := range tests {
+		e := newEntry(tt.old, tt.cur, tt.s)
+		if e.Old() != tt.old || e.New() != tt.cur || e.Status() != tt.s {
+			t.Errorf("newEntry(%d, %d, %d) = (%d, %d, %d)", tt.old, tt.cur, tt.s, e.Old(), e.New(), e.Status())
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+
+		var got Entry
+		if err := json.Unmarshal(data, &got); err != nil || got != e {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", data, got, err, e)
+		}
+	}
+
+	for _, data := range []string{`{"Old":0,"New":0,"Status":6}`, `{"Old":0,"New":0,"Status":"moved"}`, `{"Old":0,"New":536870911,"Status":"unchanged"}`} {
+		var e Entry
+		if err := json.Unmarshal([]byte(data), &e); err == nil {
+			t.Errorf("Unmarshal(%s) error = nil, want error", data)
+		}
+	}
+}
+
+func TestResult_Digest(t *testing.T) {
+	old, cur := genMixed(10_000)
+
+	r := Diff(old, cur)
+	want := r.Digest()
+
+	if got := diffP(old, cur, 3).Digest(); got != want {
+		t.Errorf("Digest() with 3 workers = %x, want %x", got, want)
+	}
+
+	// Reordering the entries does not change the digest.
+	reordered := &Result{E: slices.Clone(r.E)}
+	slices.Reverse(reordered.E)
+	for s := range numStatuses {
+		reordered.C[s].Store(r.C[s].Load())
+	}
+	if got := reordered.Digest(); got != want {
+		t.Errorf("Digest() of reordered entries = %x, want %x", got, want)
+	}
+
+	// Changing a classification changes the digest.
+	reordered.E[0] = newEntry(reordered.E[0].Old(), reordered.E[0].New(), ContentChanged)
+	if got := reordered.Digest(); got == want {
+		t.Error("Digest() did not change with a different status")
+	}
+
+	if (&Result{}).Digest() == want {
+		t.Error("Digest() of an empty result matches")
+	}
+}
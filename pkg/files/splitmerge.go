@@ -0,0 +1,97 @@
+package files
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// RelationshipKind is the kind of a split or merge Relationship.
+type RelationshipKind uint8
+
+const (
+	Split RelationshipKind = iota // One removed file was split into several added files
+	Merge                         // Several removed files were merged into one added file
+)
+
+// Relationship is a probable split or merge between Removed and Added files.
+// A Split has a single old index and a Merge has a single new index.
+type Relationship struct {
+	Kind RelationshipKind
+	Old  []uint32
+	New  []uint32
+}
+
+// leftover is a Removed or Added file with its identity.
+type leftover struct {
+	id  string
+	idx uint32
+}
+
+// SplitMergeCandidates proposes split and merge relationships among the Removed and
+// Added entries using the original file lists.
+//
+// A file's identity is the versionless name used for reconciliation (e.g., "util" for
+// "util-1.0"). A removed file with identity X is split when at least two added files
+// have identities starting with X followed by "-", "_", or "." (e.g., "util-core" and
+// "util-extra"). Conversely, at least two removed files with such identities are merged
+// when an added file has identity X. The result is heuristic: splits are ordered by old
+// index followed by merges ordered by new index, and a file may appear in more than one
+// relationship.
+func (r *Result) SplitMergeCandidates(old, cur []string) []Relationship {
+	removed := make([]leftover, 0, r.Count(Removed))
+	for e := range r.Filter(Removed) {
+		removed = append(removed, leftover{identityOf(old[e.Old()]), e.Old()})
+	}
+
+	added := make([]leftover, 0, r.Count(Added))
+	for e := range r.Filter(Added) {
+		added = append(added, leftover{identityOf(cur[e.New()]), e.New()})
+	}
+
+	// Sort both sides by identity so that files extending a prefix are adjacent.
+	byID := func(a, b leftover) int { return strings.Compare(a.id, b.id) }
+	slices.SortStableFunc(removed, byID)
+	slices.SortStableFunc(added, byID)
+
+	var splits, merges []Relationship
+	for _, l := range removed {
+		if idx := extensions(added, l.id); len(idx) >= 2 {
+			splits = append(splits, Relationship{Kind: Split, Old: []uint32{l.idx}, New: idx})
+		}
+	}
+	for _, l := range added {
+		if idx := extensions(removed, l.id); len(idx) >= 2 {
+			merges = append(merges, Relationship{Kind: Merge, Old: idx, New: []uint32{l.idx}})
+		}
+	}
+
+	slices.SortFunc(splits, func(a, b Relationship) int { return int(a.Old[0]) - int(b.Old[0]) })
+	slices.SortFunc(merges, func(a, b Relationship) int { return int(a.New[0]) - int(b.New[0]) })
+
+	return append(splits, merges...)
+}
+
+// identityOf returns the identity of a file name as a string.
+func identityOf(name string) string {
+	j, s, e := identity.Spans([]byte(name))
+	return name[:j] + name[s:e]
+}
+
+// extensions returns the sorted indices of files whose identities extend prefix
+// with a "-", "_", or "." separated suffix. The files must be sorted by identity.
+func extensions(files []leftover, prefix string) []uint32 {
+	i, _ := slices.BinarySearchFunc(files, prefix, func(f leftover, p string) int { return strings.Compare(f.id, p) })
+
+	var idx []uint32
+	for ; i < len(files) && strings.HasPrefix(files[i].id, prefix); i++ {
+		rest := files[i].id[len(prefix):]
+		if len(rest) > 1 && (rest[0] == '-' || rest[0] == '_' || rest[0] == '.') {
+			idx = append(idx, files[i].idx)
+		}
+	}
+	slices.Sort(idx)
+
+	return idx
+}
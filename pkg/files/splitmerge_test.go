@@ -0,0 +1,33 @@
+package files
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResult_SplitMergeCandidates(t *testing.T) {
+	old := []string{"usr/lib/util-1.0", "README", "usr/bin/tool-a-2.0", "usr/bin/tool-b-2.0", "usr/lib/utility-1.0"}
+	cur := []string{"usr/lib/util-core-1.1", "README", "usr/lib/util-extra-1.1", "usr/bin/tool-3.0", "usr/lib/unrelated-1.0"}
+
+	got := Diff(old, cur).SplitMergeCandidates(old, cur)
+
+	want := []Relationship{
+		{Kind: Split, Old: []uint32{0}, New: []uint32{0, 2}},
+		{Kind: Merge, Old: []uint32{2, 3}, New: []uint32{3}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b Relationship) bool {
+		return a.Kind == b.Kind && slices.Equal(a.Old, b.Old) && slices.Equal(a.New, b.New)
+	}) {
+		t.Errorf("SplitMergeCandidates() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResult_SplitMergeCandidatesNone(t *testing.T) {
+	// A single extension is a rename rather than a split.
+	old := []string{"util-1.0"}
+	cur := []string{"util-core-1.1"}
+
+	if got := Diff(old, cur).SplitMergeCandidates(old, cur); len(got) != 0 {
+		t.Errorf("SplitMergeCandidates() = %+v, want none", got)
+	}
+}
@@ -0,0 +1,81 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// insertBatch is the number of rows written per INSERT statement.
+const insertBatch = 1000
+
+// sqlEscaper escapes single quotes in standard SQL string literals.
+var sqlEscaper = strings.NewReplacer("'", "''")
+
+// WriteInsertValues writes the entries as SQL INSERT statements into table using the
+// original file lists, batching up to 1000 rows per statement:
+//
+//	INSERT INTO table (status, old_path, new_path) VALUES
+//	('updated', 'libfoo.so.1', 'libfoo.so.2'),
+//	('added', NULL, 'bar.txt');
+//
+// Paths are written as standard SQL string literals with single quotes doubled and the
+// missing side of Removed and Added entries is NULL. The table name must be an unquoted
+// identifier, optionally qualified by a schema (e.g., "audit.reconcile").
+func (r *Result) WriteInsertValues(old, cur []string, table string, w io.Writer) error {
+	return r.writeInsertValues(old, cur, table, w, insertBatch)
+}
+
+// writeInsertValues implements WriteInsertValues with a configurable batch size.
+func (r *Result) writeInsertValues(old, cur []string, table string, w io.Writer, batch int) error {
+	if !validTable(table) {
+		return fmt.Errorf("invalid table name %q", table)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for i, e := range r.E {
+		if i%batch == 0 {
+			fmt.Fprintf(bw, "INSERT INTO %s (status, old_path, new_path) VALUES\n", table)
+		}
+
+		n := e.named(old, cur)
+		fmt.Fprintf(bw, "('%s', %s, %s)", statusNames[e.Status()], sqlString(n.OldName, e.Old()), sqlString(n.NewName, e.New()))
+
+		if i%batch == batch-1 || i == len(r.E)-1 {
+			bw.WriteString(";\n")
+		} else {
+			bw.WriteString(",\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// sqlString returns s as a SQL string literal, or NULL if the index is null.
+func sqlString(s string, idx uint32) string {
+	if idx == null {
+		return "NULL"
+	}
+
+	return "'" + sqlEscaper.Replace(s) + "'"
+}
+
+// validTable reports whether name is a (possibly schema-qualified) unquoted SQL identifier.
+func validTable(name string) bool {
+	for part := range strings.SplitSeq(name, ".") {
+		if part == "" || part[0]-'0' < 10 {
+			return false
+		}
+
+		for i := range len(part) {
+			c := part[i]
+			if (c|32)-'a' >= 26 && c-'0' >= 10 && c != '_' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResult_WriteInsertValues(t *testing.T) {
+	old := []string{"libfoo.so.1", "README", "it's.txt"}
+	cur := []string{"libfoo.so.2", "README", "new'; DROP TABLE x; --"}
+
+	r := Diff(old, cur)
+
+	var b strings.Builder
+	if err := r.writeInsertValues(old, cur, "audit.reconcile", &b, 2); err != nil {
+		t.Fatalf("writeInsertValues() error = %v", err)
+	}
+
+	want := `INSERT INTO audit.reconcile (status, old_path, new_path) VALUES
+('updated', 'libfoo.so.1', 'libfoo.so.2'),
+('unchanged', 'README', 'README');
+INSERT INTO audit.reconcile (status, old_path, new_path) VALUES
+('removed', 'it''s.txt', NULL),
+('added', NULL, 'new''; DROP TABLE x; --');
+`
+	if got := b.String(); got != want {
+		t.Errorf("writeInsertValues() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// A single batch holds every row.
+	b.Reset()
+	if err := r.WriteInsertValues(old, cur, "reconcile", &b); err != nil {
+		t.Fatalf("WriteInsertValues() error = %v", err)
+	}
+	if got := strings.Count(b.String(), "INSERT INTO"); got != 1 {
+		t.Errorf("WriteInsertValues() wrote %d statements, want 1", got)
+	}
+}
+
+func TestResult_WriteInsertValuesInvalidTable(t *testing.T) {
+	for _, table := range []string{"", "a b", "x; DROP TABLE y", "1abc", "a.", `"quoted"`} {
+		var b strings.Builder
+		if err := (&Result{}).WriteInsertValues(nil, nil, table, &b); err == nil {
+			t.Errorf("WriteInsertValues(%q) error = nil, want error", table)
+		}
+	}
+}
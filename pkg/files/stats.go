@@ -0,0 +1,40 @@
+package files
+
+import "sync/atomic"
+
+// Stats counts how often hash lookups had to be verified by comparing names during a diff
+// and is only populated when Options.Stats is set. It helps validate the hashing choices
+// (e.g., Options.ConcatSpans or custom Matchers) on a given workload.
+//
+// Only the lookups for the preferred match of each old file are counted. Old files whose
+// preferred new file was claimed by an earlier old file are matched again, but those lookups
+// are not counted, so the counts are the same for any number of workers.
+type Stats struct {
+	// ExactCollisions counts exact lookups that found a new file with the same exact hash
+	// but a different name. With 63-bit hashes these are expected to be vanishingly rare.
+	ExactCollisions uint64
+
+	// IdentityComparisons counts identity lookups that found a new file and compared
+	// identities with Equal to verify the match.
+	IdentityComparisons uint64
+
+	// IdentityCollisions counts identity comparisons that failed because the names shared
+	// an identity hash but not an identity.
+	IdentityCollisions uint64
+}
+
+// counters accumulates Stats concurrently while reconciling.
+type counters struct {
+	exactCollisions     atomic.Uint64
+	identityComparisons atomic.Uint64
+	identityCollisions  atomic.Uint64
+}
+
+// stats returns a snapshot of the counters.
+func (c *counters) stats() *Stats {
+	return &Stats{
+		ExactCollisions:     c.exactCollisions.Load(),
+		IdentityComparisons: c.identityComparisons.Load(),
+		IdentityCollisions:  c.identityCollisions.Load(),
+	}
+}
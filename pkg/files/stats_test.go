@@ -0,0 +1,63 @@
+package files
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiffWithOptions_Stats(t *testing.T) {
+	// Swapped two-span names share an identity hash when the span hashes are XOR-ed
+	// (see TestConcatSpans_Collisions), whatever the seed.
+	old := []string{"ab@cd", "lib/libfoo.so.1", "README"}
+	cur := []string{"cd@ab", "lib/libfoo.so.2", "README"}
+
+	if r := Diff(old, cur); r.Stats != nil {
+		t.Errorf("Stats = %+v without Options.Stats, want nil", r.Stats)
+	}
+
+	r := mustDiff(t, old, cur, Options{Matchers: []Matcher{swapped}, Stats: true})
+	want := Stats{IdentityComparisons: 2, IdentityCollisions: 1}
+	if r.Stats == nil || *r.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", r.Stats, want)
+	}
+
+	// Hashing the concatenated spans avoids the collision without changing the result.
+	concat := mustDiff(t, old, cur, Options{Matchers: []Matcher{swapped}, ConcatSpans: true, Stats: true})
+	want = Stats{IdentityComparisons: 1}
+	if concat.Stats == nil || *concat.Stats != want {
+		t.Errorf("Stats with ConcatSpans = %+v, want %+v", concat.Stats, want)
+	}
+	if concat.Count(Updated) != r.Count(Updated) || concat.Count(Removed) != r.Count(Removed) {
+		t.Error("ConcatSpans changed the result")
+	}
+
+	// One-sided diffs do no lookups.
+	r = mustDiff(t, old, nil, Options{Stats: true})
+	if r.Stats == nil || *r.Stats != (Stats{}) {
+		t.Errorf("Stats without new files = %+v, want zero", r.Stats)
+	}
+}
+
+func TestDiffWithOptions_StatsWorkers(t *testing.T) {
+	// Several old files prefer the same new files, so conflicts are matched again.
+	var old, cur []string
+	for i := range 1000 {
+		old = append(old, fmt.Sprintf("lib/libfoo%d.so.%d", i%50, i), fmt.Sprintf("ab%d@cd", i%7))
+		cur = append(cur, fmt.Sprintf("lib/libfoo%d.so.%d", i%60, i+1), fmt.Sprintf("cd@ab%d", i%5))
+	}
+
+	opts := Options{Matchers: []Matcher{swapped}, Stats: true, Workers: 1}
+	want := mustDiff(t, old, cur, opts)
+	if want.Stats.IdentityComparisons == 0 || want.Stats.IdentityCollisions == 0 {
+		t.Fatalf("Stats = %+v, want identity comparisons and collisions", want.Stats)
+	}
+
+	for _, workers := range []int{2, 3, 8} {
+		for _, ws := range []bool{false, true} {
+			opts.Workers, opts.WorkStealing = workers, ws
+			if r := mustDiff(t, old, cur, opts); *r.Stats != *want.Stats {
+				t.Errorf("workers=%d stealing=%v: Stats = %+v, want %+v", workers, ws, r.Stats, want.Stats)
+			}
+		}
+	}
+}
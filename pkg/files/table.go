@@ -0,0 +1,101 @@
+package files
+
+import (
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/egibs/reconcile/internal/identity"
+)
+
+// table is an open-addressing hash table with linear probing that maps the identity and
+// exact keys of new files to their indices. It is filled concurrently using atomic
+// compare-and-swap instead of locks and only read once it is complete.
+//
+// Exact keys use a file's hash OR'd with the exact flag (hash | identity.ExactFlag) and
+// identity keys just use a file's hash, so both kinds of keys share one table.
+type table struct {
+	keys []atomic.Uint64 // Key of each slot (0 for empty slots)
+	vals []atomic.Uint32 // File index + 1 of each slot (0 until a value is stored)
+	mask uint64
+}
+
+// tableSlots returns the number of slots for n keys, a power of two that keeps the
+// load factor at or below 2/3.
+func tableSlots(n int) int {
+	return 1 << bits.Len(uint(max(8, n+n/2)-1))
+}
+
+// reset empties the table and sizes it for n keys, reusing its slots when they suffice.
+func (t *table) reset(n int) {
+	slots := tableSlots(n)
+	if cap(t.keys) < slots {
+		t.keys, t.vals = make([]atomic.Uint64, slots), make([]atomic.Uint32, slots)
+	} else {
+		t.keys, t.vals = t.keys[:slots], t.vals[:slots]
+		clear(t.keys)
+		clear(t.vals)
+	}
+	t.mask = uint64(slots - 1)
+}
+
+// slotKey maps a key to its stored form. Zero marks empty slots, so an identity hash of
+// zero shares a slot with an identity hash of one; like any other hash collision, this is
+// resolved by comparing names.
+func slotKey(key uint64) uint64 {
+	if key == 0 {
+		return 1
+	}
+
+	return key
+}
+
+// insert stores file index i under key, replacing an existing index when tb prefers i.
+func (t *table) insert(key uint64, i uint32, tb TieBreak) {
+	key = slotKey(key)
+
+	for s := key & t.mask; ; s = (s + 1) & t.mask {
+		k := t.keys[s].Load()
+		if k == 0 {
+			if !t.keys[s].CompareAndSwap(0, key) {
+				k = t.keys[s].Load() // Another worker claimed the slot, possibly for the same key
+			} else {
+				k = key
+			}
+		}
+		if k != key {
+			continue
+		}
+
+		v := &t.vals[s]
+		for {
+			prev := v.Load()
+			if prev != 0 && !tb.prefer(i, prev-1) || v.CompareAndSwap(prev, i+1) {
+				return
+			}
+		}
+	}
+}
+
+// get returns the file index stored under key.
+func (t *table) get(key uint64) (uint32, bool) {
+	key = slotKey(key)
+
+	for s := key & t.mask; ; s = (s + 1) & t.mask {
+		switch t.keys[s].Load() {
+		case key:
+			return t.vals[s].Load() - 1, true
+		case 0:
+			return null, false
+		}
+	}
+}
+
+// add stores the identity and exact keys of new file i.
+// Only one new file is stored per identity, chosen by tb so that the result doesn't depend
+// on worker order. For exact keys the last occurrence of a name (the highest index) is kept;
+// an old file whose name appears at its own index in the new list is paired with that file
+// before the table is consulted.
+func (t *table) add(idHash, exactHash uint64, i uint32, tb TieBreak) {
+	t.insert(idHash, i, tb)
+	t.insert(exactHash|identity.ExactFlag, i, HighestIndex)
+}
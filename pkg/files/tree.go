@@ -0,0 +1,83 @@
+package files
+
+import (
+	"slices"
+	"strings"
+)
+
+// Node is a single path segment within a reconciliation tree.
+// Each node aggregates the statuses of every entry at or below it.
+type Node struct {
+	Name     string              // Path segment (empty for the root)
+	Counts   [numStatuses]uint32 // Aggregated status counts for this node and its descendants
+	Children []*Node             // Child segments sorted by name
+	Entries  []Entry             // Entries whose path ends at this node
+
+	index map[string]*Node
+}
+
+// Count returns the aggregated number of entries with the given status at or below this node.
+func (n *Node) Count(s Status) uint32 { return n.Counts[s] }
+
+// Walk calls fn for this node and each descendant in depth-first order.
+// The depth of the root node is zero.
+func (n *Node) Walk(fn func(depth int, n *Node)) {
+	n.walk(0, fn)
+}
+
+func (n *Node) walk(depth int, fn func(int, *Node)) {
+	fn(depth, n)
+	for _, c := range n.Children {
+		c.walk(depth+1, fn)
+	}
+}
+
+// child returns the child node for name, creating it if necessary.
+func (n *Node) child(name string) *Node {
+	if c, ok := n.index[name]; ok {
+		return c
+	}
+
+	if n.index == nil {
+		n.index = make(map[string]*Node)
+	}
+
+	c := &Node{Name: name}
+	n.index[name] = c
+	n.Children = append(n.Children, c)
+
+	return c
+}
+
+// Tree builds a directory tree of all entries keyed by "/"-separated path segments.
+// Removed entries are placed at their old path; all other entries are placed at their new path.
+func (r *Result) Tree(old, cur []string) *Node {
+	root := &Node{}
+
+	for s, e := range r.All() {
+		var p string
+		if s == Removed {
+			p = old[e.Old()]
+		} else {
+			p = cur[e.New()]
+		}
+
+		n := root
+		n.Counts[s]++
+		for seg := range strings.SplitSeq(p, "/") {
+			if seg == "" {
+				continue
+			}
+			n = n.child(seg)
+			n.Counts[s]++
+		}
+		n.Entries = append(n.Entries, e)
+	}
+
+	root.Walk(func(_ int, n *Node) {
+		slices.SortFunc(n.Children, func(a, b *Node) int { return strings.Compare(a.Name, b.Name) })
+		n.index = nil
+	})
+
+	return root
+}
@@ -0,0 +1,42 @@
+package files
+
+import "testing"
+
+func TestResult_Tree(t *testing.T) {
+	old := []string{"usr/lib/libfoo.so.1", "usr/lib/libbar.so.1", "usr/bin/tool", "etc/old.conf"}
+	cur := []string{"usr/lib/libfoo.so.2", "usr/lib/libbar.so.1", "usr/bin/tool", "etc/new.conf"}
+
+	r := Diff(old, cur)
+	root := r.Tree(old, cur)
+
+	if got := root.Count(Unchanged) + root.Count(Updated) + root.Count(Removed) + root.Count(Added); int(got) != len(r.E) {
+		t.Fatalf("root counts = %d, want %d", got, len(r.E))
+	}
+
+	if len(root.Children) != 2 || root.Children[0].Name != "etc" || root.Children[1].Name != "usr" {
+		t.Fatalf("root children = %+v, want [etc usr]", root.Children)
+	}
+
+	etc, usr := root.Children[0], root.Children[1]
+	if etc.Count(Removed) != 1 || etc.Count(Added) != 1 {
+		t.Errorf("etc: removed=%d added=%d, want 1 and 1", etc.Count(Removed), etc.Count(Added))
+	}
+
+	if usr.Count(Unchanged) != 2 || usr.Count(Updated) != 1 {
+		t.Errorf("usr: unchanged=%d updated=%d, want 2 and 1", usr.Count(Unchanged), usr.Count(Updated))
+	}
+
+	paths := map[string]int{}
+	root.Walk(func(depth int, n *Node) {
+		if len(n.Entries) > 0 {
+			paths[n.Name] = depth
+		}
+	})
+
+	want := map[string]int{"old.conf": 2, "new.conf": 2, "libfoo.so.2": 3, "libbar.so.1": 3, "tool": 3}
+	for name, depth := range want {
+		if got, ok := paths[name]; !ok || got != depth {
+			t.Errorf("leaf %q at depth %d (found=%v), want %d", name, got, ok, depth)
+		}
+	}
+}